@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maintenance is set while the site is in maintenance mode. It starts
+// from MAINTENANCE and can be flipped at runtime via /admin/maintenance.
+var maintenance atomic.Bool
+
+// requireAdmin checks the admin token, sent as "Authorization: Bearer
+// <token>". With no ADMIN_TOKEN configured the admin routes don't exist.
+// It writes the error response itself and reports whether to continue.
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if cfg.AdminToken == "" {
+		writeJSONError(w, r, http.StatusNotFound, errNotFound, "Not Found")
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.AdminToken)) != 1 {
+		writeJSONError(w, r, http.StatusUnauthorized, errUnauthorized, "Invalid or missing admin token")
+		return false
+	}
+	return true
+}
+
+// handleMaintenance reports the maintenance state on GET and changes it
+// on POST with enabled=1 or enabled=0.
+func handleMaintenance(w http.ResponseWriter, r *http.Request) {
+
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPost:
+		switch r.FormValue("enabled") {
+		case "1":
+			maintenance.Store(true)
+		case "0":
+			maintenance.Store(false)
+		default:
+			writeJSONError(w, r, http.StatusBadRequest, errInvalidParameter, "enabled must be 0 or 1")
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST, OPTIONS")
+		writeJSONError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, map[string]bool{"maintenance": maintenance.Load()})
+}
+
+// handleHealthz reports that the process is up. It answers even in
+// maintenance mode, which it mentions so deploy tooling can tell. The
+// status is "degraded" once the cached data is older than cfg.StaleAfter.
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+
+	if !allowGetHead(w, r) {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	status := "ok"
+	stale := false
+	if n := lastFetched.Load(); n != 0 && time.Since(time.Unix(0, n)) > cfg.StaleAfter {
+		status = "degraded"
+		stale = true
+	}
+
+	writeJSON(w, r, http.StatusOK, map[string]any{
+		"status":      status,
+		"stale":       stale,
+		"maintenance": maintenance.Load(),
+	})
+}
+
+// rawCache holds the upstream bodies served by /admin/raw/, each reused
+// for cacheTTL like the dataset.
+var rawCache struct {
+	sync.Mutex
+	bodies map[string]rawBody
+}
+
+type rawBody struct {
+	body    []byte
+	fetched time.Time
+}
+
+// handleRawUpstream serves /admin/raw/{endpoint}: the upstream response
+// body exactly as received, for telling parsing bugs from bad source data.
+func handleRawUpstream(w http.ResponseWriter, r *http.Request) {
+
+	if !requireAdmin(w, r) {
+		return
+	}
+	if !allowGetHead(w, r) {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	endpoint := strings.TrimPrefix(r.URL.Path, "/admin/raw/")
+	if !slices.Contains(upstreamEndpoints, endpoint) {
+		writeJSONError(w, r, http.StatusNotFound, errNotFound, "Unknown upstream endpoint")
+		return
+	}
+
+	raw, err := loadRawUpstream(r.Context(), endpoint)
+	if err != nil {
+		writeFetchError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Last-Modified", raw.fetched.UTC().Format(http.TimeFormat))
+	w.Write(raw.body)
+}
+
+// loadRawUpstream returns the cached body of endpoint, fetching it when
+// missing or older than cacheTTL.
+func loadRawUpstream(ctx context.Context, endpoint string) (rawBody, error) {
+	rawCache.Lock()
+	defer rawCache.Unlock()
+
+	if raw, ok := rawCache.bodies[endpoint]; ok && time.Since(raw.fetched) < cacheTTL {
+		return raw, nil
+	}
+
+	body, err := openUpstream(ctx, endpoint)
+	if err != nil {
+		return rawBody{}, err
+	}
+	defer body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(body, cfg.MaxUpstreamBytes+1))
+	if err != nil {
+		return rawBody{}, err
+	}
+	if int64(len(b)) > cfg.MaxUpstreamBytes {
+		return rawBody{}, fmt.Errorf("%s: response larger than %d bytes", endpoint, cfg.MaxUpstreamBytes)
+	}
+
+	raw := rawBody{body: b, fetched: time.Now()}
+	if rawCache.bodies == nil {
+		rawCache.bodies = make(map[string]rawBody)
+	}
+	rawCache.bodies[endpoint] = raw
+	return raw, nil
+}
+
+// PublicConfig is the effective configuration minus secrets, served by
+// /admin/config. Secrets are reported only as whether they are set; new
+// Config fields stay hidden until added here.
+type PublicConfig struct {
+	Addr       string `json:"addr"`
+	BasePath   string `json:"basePath"`
+	APIBaseURL string `json:"apiBaseURL"`
+	UserAgent  string `json:"userAgent"`
+	Offline    bool   `json:"offline"`
+
+	TemplateDir string `json:"templateDir"`
+	StaticDir   string `json:"staticDir"`
+
+	CacheTTL            string `json:"cacheTTL"`
+	StaleAfter          string `json:"staleAfter"`
+	GeoCacheSize        int    `json:"geoCacheSize"`
+	ImageCacheSize      int    `json:"imageCacheSize"`
+	PageCacheSize       int    `json:"pageCacheSize"`
+	PrefetchImages      bool   `json:"prefetchImages"`
+	ImageProxy          bool   `json:"imageProxy"`
+	PrecomputePages     bool   `json:"precomputePages"`
+	MaxPrecomputedPages int    `json:"maxPrecomputedPages"`
+	MaxUpstreamBytes    int64  `json:"maxUpstreamBytes"`
+
+	RequestTimeout    string `json:"requestTimeout"`
+	ReadTimeout       string `json:"readTimeout"`
+	ReadHeaderTimeout string `json:"readHeaderTimeout"`
+	WriteTimeout      string `json:"writeTimeout"`
+	IdleTimeout       string `json:"idleTimeout"`
+
+	MaxConcurrentRequests int      `json:"maxConcurrentRequests"`
+	RateLimit             int      `json:"rateLimit"`
+	TrustedProxies        []string `json:"trustedProxies"`
+	CORSOrigin            string   `json:"corsOrigin"`
+
+	MinQueryLength       int  `json:"minQueryLength"`
+	ShortQueryPrompt     bool `json:"shortQueryPrompt"`
+	SearchWeightName     int  `json:"searchWeightName"`
+	SearchWeightMember   int  `json:"searchWeightMember"`
+	SearchWeightLocation int  `json:"searchWeightLocation"`
+	MaxCompare           int  `json:"maxCompare"`
+
+	TolerateTemplateErrors bool   `json:"tolerateTemplateErrors"`
+	StrictStartup          bool   `json:"strictStartup"`
+	StrictParams           bool   `json:"strictParams"`
+	LogRequests            bool   `json:"logRequests"`
+	LogFile                string `json:"logFile"`
+	SavedQueriesFile       string `json:"savedQueriesFile"`
+	Maintenance            bool   `json:"maintenance"`
+
+	APIKeySet bool `json:"apiKeySet"`
+}
+
+func publicConfig(c Config) PublicConfig {
+	proxies := make([]string, len(c.TrustedProxies))
+	for i, p := range c.TrustedProxies {
+		proxies[i] = p.String()
+	}
+
+	// a password in the upstream URL is a secret too
+	apiBaseURL := c.APIBaseURL
+	if u, err := url.Parse(apiBaseURL); err == nil {
+		apiBaseURL = u.Redacted()
+	}
+
+	return PublicConfig{
+		Addr:       listenAddr,
+		BasePath:   c.BasePath,
+		APIBaseURL: apiBaseURL,
+		UserAgent:  c.UserAgent,
+		Offline:    c.Offline,
+
+		TemplateDir: c.TemplateDir,
+		StaticDir:   c.StaticDir,
+
+		CacheTTL:            cacheTTL.String(),
+		StaleAfter:          c.StaleAfter.String(),
+		GeoCacheSize:        c.GeoCacheSize,
+		ImageCacheSize:      c.ImageCacheSize,
+		PageCacheSize:       c.PageCacheSize,
+		PrefetchImages:      c.PrefetchImages,
+		ImageProxy:          c.ImageProxy,
+		PrecomputePages:     c.PrecomputePages,
+		MaxPrecomputedPages: c.MaxPrecomputedPages,
+		MaxUpstreamBytes:    c.MaxUpstreamBytes,
+
+		RequestTimeout:    c.RequestTimeout.String(),
+		ReadTimeout:       c.ReadTimeout.String(),
+		ReadHeaderTimeout: c.ReadHeaderTimeout.String(),
+		WriteTimeout:      c.WriteTimeout.String(),
+		IdleTimeout:       c.IdleTimeout.String(),
+
+		MaxConcurrentRequests: c.MaxConcurrentRequests,
+		RateLimit:             c.RateLimit,
+		TrustedProxies:        proxies,
+		CORSOrigin:            c.CORSOrigin,
+
+		MinQueryLength:       c.MinQueryLength,
+		ShortQueryPrompt:     c.ShortQueryPrompt,
+		SearchWeightName:     c.SearchWeightName,
+		SearchWeightMember:   c.SearchWeightMember,
+		SearchWeightLocation: c.SearchWeightLocation,
+		MaxCompare:           c.MaxCompare,
+
+		TolerateTemplateErrors: c.TolerateTemplateErrors,
+		StrictStartup:          c.StrictStartup,
+		StrictParams:           c.StrictParams,
+		LogRequests:            c.LogRequests,
+		LogFile:                c.LogFile,
+		SavedQueriesFile:       c.SavedQueriesFile,
+		Maintenance:            maintenance.Load(),
+
+		APIKeySet: c.APIKey != "",
+	}
+}
+
+// handleConfig serves the effective non-secret configuration, to check
+// which settings took effect.
+func handleConfig(w http.ResponseWriter, r *http.Request) {
+
+	if !requireAdmin(w, r) {
+		return
+	}
+	if !allowGetHead(w, r) {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, publicConfig(cfg))
+}
@@ -0,0 +1,784 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+type GeoJSONFeature struct {
+	Type       string            `json:"type"`
+	Geometry   GeoJSONGeometry   `json:"geometry"`
+	Properties GeoJSONProperties `json:"properties"`
+}
+
+type GeoJSONGeometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+type GeoJSONProperties struct {
+	Location string   `json:"location"`
+	Dates    []string `json:"dates"`
+}
+
+func handleArtistGeoJSON(w http.ResponseWriter, r *http.Request) {
+
+	if !allowGetHead(w, r) {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	id, err := parseID(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, errInvalidID, err.Error())
+		return
+	}
+
+	data, err := loadAllData(r.Context())
+	if err != nil {
+		writeFetchError(w, r, err)
+		return
+	}
+
+	if _, found := data.Artist(id); !found {
+		writeJSONError(w, r, http.StatusNotFound, errArtistNotFound, "Artist not found")
+		return
+	}
+
+	collection := GeoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: []GeoJSONFeature{},
+	}
+
+	// features in location order, so the same data gives the same body
+	datesLocations := data.DatesLocations[id]
+	for _, location := range slices.Sorted(maps.Keys(datesLocations)) {
+		dates := datesLocations[location]
+		coords, ok := geocode(r.Context(), location)
+		// geocoding is slow on a cold cache and gives up when the budget
+		// is spent; don't pass the locations it skipped off as misses
+		if r.Context().Err() != nil {
+			writeJSONError(w, r, http.StatusGatewayTimeout, errTimeout, "Request timed out")
+			return
+		}
+		if !ok {
+			continue
+		}
+		collection.Features = append(collection.Features, GeoJSONFeature{
+			Type: "Feature",
+			Geometry: GeoJSONGeometry{
+				Type:        "Point",
+				Coordinates: []float64{coords.Lon, coords.Lat},
+			},
+			Properties: GeoJSONProperties{
+				Location: prettifyLocation(location),
+				Dates:    dates,
+			},
+		})
+	}
+
+	writeJSON(w, r, http.StatusOK, collection)
+}
+
+// ArtistBBox is the bounding box of an artist's geocoded locations, for
+// zooming a map to fit the tour. BBox is null when none geocode.
+type ArtistBBox struct {
+	BBox      *BoundingBox `json:"bbox"`
+	Located   int          `json:"located"`
+	Locations int          `json:"locations"`
+}
+
+type BoundingBox struct {
+	MinLat float64 `json:"minLat"`
+	MinLon float64 `json:"minLon"`
+	MaxLat float64 `json:"maxLat"`
+	MaxLon float64 `json:"maxLon"`
+}
+
+// extend grows the box to include c.
+func (b *BoundingBox) extend(c Coordinates) {
+	b.MinLat = min(b.MinLat, c.Lat)
+	b.MinLon = min(b.MinLon, c.Lon)
+	b.MaxLat = max(b.MaxLat, c.Lat)
+	b.MaxLon = max(b.MaxLon, c.Lon)
+}
+
+func handleArtistBBox(w http.ResponseWriter, r *http.Request) {
+
+	if !allowGetHead(w, r) {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	id, err := parseID(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, errInvalidID, err.Error())
+		return
+	}
+
+	data, err := loadAllData(r.Context())
+	if err != nil {
+		writeFetchError(w, r, err)
+		return
+	}
+
+	if _, found := data.Artist(id); !found {
+		writeJSONError(w, r, http.StatusNotFound, errArtistNotFound, "Artist not found")
+		return
+	}
+
+	locations := data.Locations[id]
+	result := ArtistBBox{Locations: len(locations)}
+	for _, location := range locations {
+		coords, ok := geocode(r.Context(), location)
+		// geocoding is slow on a cold cache and gives up when the budget
+		// is spent; don't pass the locations it skipped off as misses
+		if r.Context().Err() != nil {
+			writeJSONError(w, r, http.StatusGatewayTimeout, errTimeout, "Request timed out")
+			return
+		}
+		if !ok {
+			continue
+		}
+		if result.BBox == nil {
+			result.BBox = &BoundingBox{MinLat: coords.Lat, MinLon: coords.Lon, MaxLat: coords.Lat, MaxLon: coords.Lon}
+		} else {
+			result.BBox.extend(coords)
+		}
+		result.Located++
+	}
+
+	writeJSON(w, r, http.StatusOK, result)
+}
+
+// ArtistsPage is one page of /api/artists/page results.
+type ArtistsPage struct {
+	Artists    []Artist `json:"artists"`
+	Total      int      `json:"total"`
+	NextCursor string   `json:"nextCursor"`
+}
+
+// handleArtistsPage serves /api/artists/page, the infinite-scroll twin of
+// the index: same filters, returned limit artists at a time. The cursor
+// is opaque to clients; an empty nextCursor means the end was reached.
+func handleArtistsPage(w http.ResponseWriter, r *http.Request) {
+
+	if !allowGetHead(w, r) {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	values := r.URL.Query()
+	params, err := parseIndexParams(values)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, errInvalidParameter, err.Error())
+		return
+	}
+
+	limit, err := parseLimit(values)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, errInvalidParameter, err.Error())
+		return
+	}
+
+	cursor, err := singleParam(values, "cursor")
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, errInvalidParameter, err.Error())
+		return
+	}
+	offset := 0
+	if cursor != "" {
+		offset, err = strconv.Atoi(cursor)
+		if err != nil || offset < 0 {
+			writeJSONError(w, r, http.StatusBadRequest, errInvalidParameter, "Invalid cursor")
+			return
+		}
+	}
+
+	data, err := loadAllData(r.Context())
+	if err != nil {
+		writeFetchError(w, r, err)
+		return
+	}
+	if err := params.checkKnownValues(data); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, errInvalidParameter, err.Error())
+		return
+	}
+
+	if params.Sort == "concerts" && data.DatesLocations == nil {
+		writeJSONError(w, r, http.StatusServiceUnavailable, errUpstreamUnavailable, "Concert data unavailable")
+		return
+	}
+
+	if notModified(w, r, data.FetchedAt) {
+		return
+	}
+
+	filtered := filterArtists(data, params, time.Now())
+
+	start := min(offset, len(filtered))
+	end := min(start+limit, len(filtered))
+
+	page := ArtistsPage{
+		Artists: filtered[start:end],
+		Total:   len(filtered),
+	}
+	if page.Artists == nil {
+		page.Artists = []Artist{}
+	}
+	if end < len(filtered) {
+		page.NextCursor = strconv.Itoa(end)
+	}
+
+	writeJSON(w, r, http.StatusOK, page)
+}
+
+// SearchResult is one page of /api/search: the matching artists, the
+// filters as applied and the facet options to offer next.
+type SearchResult struct {
+	Artists    []Artist       `json:"artists"`
+	Total      int            `json:"total"`
+	Page       int            `json:"page"`
+	PageSize   int            `json:"pageSize"`
+	TotalPages int            `json:"totalPages"`
+	Filters    AppliedFilters `json:"filters"`
+	Facets     FilterOptions  `json:"facets"`
+}
+
+// AppliedFilters echoes the parsed index parameters; inactive ones are
+// left out.
+type AppliedFilters struct {
+	Query      string   `json:"q,omitempty"`
+	TermMode   string   `json:"terms"`
+	StartsWith string   `json:"startsWith,omitempty"`
+	Members    []string `json:"members,omitempty"`
+	Location   string   `json:"location,omitempty"`
+	Year       int      `json:"year,omitempty"`
+	Timeframe  string   `json:"timeframe,omitempty"`
+	Complete   bool     `json:"complete,omitempty"`
+	Match      string   `json:"match"`
+	Sort       string   `json:"sort,omitempty"`
+}
+
+func appliedFilters(p IndexParams) AppliedFilters {
+	f := AppliedFilters{
+		TermMode:   p.TermMode,
+		StartsWith: p.StartsWith,
+		Location:   p.Location,
+		Year:       p.Year,
+		Timeframe:  p.Timeframe,
+		Complete:   p.Complete,
+		Match:      p.Match,
+		Sort:       p.Sort,
+	}
+	if !p.QueryTooShort {
+		f.Query = p.Query
+	}
+	for _, mr := range p.Members {
+		f.Members = append(f.Members, mr.String())
+	}
+	return f
+}
+
+// handleSearch serves /api/search, the JSON twin of the index: it takes
+// the same parameters, paginates the same way and also returns the
+// facet options, so a client can rebuild the whole page.
+func handleSearch(w http.ResponseWriter, r *http.Request) {
+
+	if !allowGetHead(w, r) {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	serveSearch(w, r, r.URL.Query())
+}
+
+// serveSearch answers with the SearchResult for the index parameters in
+// values.
+func serveSearch(w http.ResponseWriter, r *http.Request, values url.Values) {
+	params, err := parseIndexParams(values)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, errInvalidParameter, err.Error())
+		return
+	}
+
+	data, err := loadAllData(r.Context())
+	if err != nil {
+		writeFetchError(w, r, err)
+		return
+	}
+	if err := params.checkKnownValues(data); err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, errInvalidParameter, err.Error())
+		return
+	}
+
+	if params.Sort == "concerts" && data.DatesLocations == nil {
+		writeJSONError(w, r, http.StatusServiceUnavailable, errUpstreamUnavailable, "Concert data unavailable")
+		return
+	}
+
+	if notModified(w, r, data.FetchedAt) {
+		return
+	}
+
+	filtered := filterArtists(data, params, time.Now())
+
+	total := len(filtered)
+	totalPages := max((total+params.PageSize-1)/params.PageSize, 1)
+	page := min(max(params.Page, 1), totalPages)
+	start := (page - 1) * params.PageSize
+	end := min(start+params.PageSize, total)
+
+	result := SearchResult{
+		Artists:    filtered[start:end],
+		Total:      total,
+		Page:       page,
+		PageSize:   params.PageSize,
+		TotalPages: totalPages,
+		Filters:    appliedFilters(params),
+		Facets:     buildFilterOptions(data),
+	}
+	if result.Artists == nil {
+		result.Artists = []Artist{}
+	}
+
+	writeJSON(w, r, http.StatusOK, result)
+}
+
+// handleTimeline serves /api/timeline: the number of concerts per year
+// across all artists. Dates that don't parse are skipped.
+func handleTimeline(w http.ResponseWriter, r *http.Request) {
+
+	if !allowGetHead(w, r) {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	data, err := loadAllData(r.Context())
+	if err != nil {
+		writeFetchError(w, r, err)
+		return
+	}
+
+	if notModified(w, r, data.FetchedAt) {
+		return
+	}
+
+	timeline := make(map[int]int)
+	for _, dates := range data.Dates {
+		for _, d := range dates {
+			if t, err := parseConcertDate(d); err == nil {
+				timeline[t.Year()]++
+			}
+		}
+	}
+
+	writeJSON(w, r, http.StatusOK, timeline)
+}
+
+// handleCountries serves /api/countries: for each country, the IDs of the
+// artists who played there. Locations without a country part are
+// grouped under "unknown".
+func handleCountries(w http.ResponseWriter, r *http.Request) {
+
+	if !allowGetHead(w, r) {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	data, err := loadAllData(r.Context())
+	if err != nil {
+		writeFetchError(w, r, err)
+		return
+	}
+
+	if notModified(w, r, data.FetchedAt) {
+		return
+	}
+
+	seen := make(map[string]map[int]bool)
+	for id, locations := range data.Locations {
+		for _, loc := range locations {
+			country := locationCountry(loc)
+			if seen[country] == nil {
+				seen[country] = make(map[int]bool)
+			}
+			seen[country][id] = true
+		}
+	}
+
+	countries := make(map[string][]int, len(seen))
+	for country, ids := range seen {
+		for id := range ids {
+			countries[country] = append(countries[country], id)
+		}
+		sort.Ints(countries[country])
+	}
+
+	writeJSON(w, r, http.StatusOK, countries)
+}
+
+// writeJSON encodes v as the response body. Output is compact unless the
+// request asks for pretty=1, which is handy when exploring in a browser.
+func writeJSON(w http.ResponseWriter, r *http.Request, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	enc := json.NewEncoder(w)
+	if r.URL.Query().Get("pretty") == "1" {
+		enc.SetIndent("", "  ")
+	}
+	enc.Encode(v)
+}
+
+// notModified sets Last-Modified to the dataset's refresh time and, when
+// the client's If-Modified-Since is no older than that, answers 304 and
+// reports true. The upstream has no per-artist timestamps, so the whole
+// dataset counts as modified whenever it is refreshed.
+func notModified(w http.ResponseWriter, r *http.Request, fetchedAt time.Time) bool {
+	lastModified := fetchedAt.UTC().Truncate(time.Second)
+	w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || lastModified.After(since) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// Error codes sent in APIError.Code. They are stable, unlike the
+// messages, so clients can branch on them.
+const (
+	errMethodNotAllowed    = "METHOD_NOT_ALLOWED"
+	errInvalidParameter    = "INVALID_PARAMETER"
+	errInvalidID           = "INVALID_ID"
+	errArtistNotFound      = "ARTIST_NOT_FOUND"
+	errNotFound            = "NOT_FOUND"
+	errUpstreamUnavailable = "UPSTREAM_UNAVAILABLE"
+	errTimeout             = "TIMEOUT"
+	errUnauthorized        = "UNAUTHORIZED"
+	errServerBusy          = "SERVER_BUSY"
+	errInternal            = "INTERNAL_ERROR"
+	errRateLimited         = "RATE_LIMITED"
+	errMaintenance         = "MAINTENANCE"
+)
+
+// APIError is the body of every JSON error response.
+type APIError struct {
+	Error  string `json:"error"`
+	Code   string `json:"code"`
+	Status int    `json:"status"`
+}
+
+func writeJSONError(w http.ResponseWriter, r *http.Request, status int, code, msg string) {
+	writeJSON(w, r, status, APIError{Error: msg, Code: code, Status: status})
+}
+
+// writeFetchError reports a loadAllData failure: a timeout when the
+// request's deadline passed, an unavailable upstream otherwise.
+func writeFetchError(w http.ResponseWriter, r *http.Request, err error) {
+	status := fetchErrorStatus(err)
+	code := errUpstreamUnavailable
+	if status == http.StatusGatewayTimeout {
+		code = errTimeout
+	}
+	writeJSONError(w, r, status, code, "Failed to fetch artists")
+}
+
+// RelationEntry is one concert: a date and the location slug played.
+type RelationEntry struct {
+	Date     string `json:"date"`
+	Location string `json:"location"`
+}
+
+// relationEntries flattens an artist's datesLocations into entries in
+// chronological order. Dates that don't parse go last; ties are broken
+// by location so the output is stable.
+func relationEntries(datesLocations map[string][]string) []RelationEntry {
+	entries := []RelationEntry{}
+	for location, dates := range datesLocations {
+		for _, d := range dates {
+			entries = append(entries, RelationEntry{Date: d, Location: location})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		ti, erri := parseConcertDate(entries[i].Date)
+		tj, errj := parseConcertDate(entries[j].Date)
+		switch {
+		case erri != nil || errj != nil:
+			if (erri == nil) != (errj == nil) {
+				return erri == nil
+			}
+		case !ti.Equal(tj):
+			return ti.Before(tj)
+		}
+		return entries[i].Location < entries[j].Location
+	})
+	return entries
+}
+
+func handleRelation(w http.ResponseWriter, r *http.Request) {
+
+	if !allowGetHead(w, r) {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	id, err := parseID(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, errInvalidID, err.Error())
+		return
+	}
+
+	data, err := loadAllData(r.Context())
+	if err != nil {
+		writeFetchError(w, r, err)
+		return
+	}
+
+	// without the relation endpoint there is no telling whether the
+	// artist has concerts, so that isn't answered as a 404
+	if data.DatesLocations == nil {
+		if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+			writeJSONError(w, r, http.StatusGatewayTimeout, errTimeout, "Concert data unavailable")
+			return
+		}
+		writeJSONError(w, r, http.StatusServiceUnavailable, errUpstreamUnavailable, "Concert data unavailable")
+		return
+	}
+
+	if notModified(w, r, data.FetchedAt) {
+		return
+	}
+
+	datesLocations := data.DatesLocations[id]
+	if len(datesLocations) == 0 {
+		writeJSONError(w, r, http.StatusNotFound, errNotFound, "No relation data for artist")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, relationEntries(datesLocations))
+}
+
+func handleFilters(w http.ResponseWriter, r *http.Request) {
+
+	if !allowGetHead(w, r) {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	data, err := loadAllData(r.Context())
+	if err != nil {
+		writeFetchError(w, r, err)
+		return
+	}
+
+	if notModified(w, r, data.FetchedAt) {
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, buildFilterOptions(data))
+}
+
+// handleLocations serves a page of the dataset's distinct locations,
+// selected with limit and offset. The total is sent in X-Total-Count.
+func handleLocations(w http.ResponseWriter, r *http.Request) {
+
+	if !allowGetHead(w, r) {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	limit, offset, err := parseLimitOffset(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, errInvalidParameter, err.Error())
+		return
+	}
+
+	data, err := loadAllData(r.Context())
+	if err != nil {
+		writeFetchError(w, r, err)
+		return
+	}
+
+	if notModified(w, r, data.FetchedAt) {
+		return
+	}
+
+	index := data.LocationIndex
+	start := min(offset, len(index))
+	end := min(start+limit, len(index))
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(index)))
+	writeJSON(w, r, http.StatusOK, index[start:end])
+}
+
+// parseLimit reads the limit parameter of the JSON listings, which
+// defaults and clamps like the index's pageSize; see parsePageSize.
+func parseLimit(values url.Values) (int, error) {
+	limitStr, err := singleParam(values, "limit")
+	if err != nil {
+		return 0, err
+	}
+	limit, err := parsePageSize(limitStr)
+	if err != nil {
+		return 0, errors.New("Invalid limit")
+	}
+	return limit, nil
+}
+
+// parseLimitOffset reads the limit and offset parameters of the listing
+// endpoints: limit as parseLimit does, offset defaulting to 0.
+func parseLimitOffset(values url.Values) (limit, offset int, err error) {
+	limit, err = parseLimit(values)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	offsetStr, err := singleParam(values, "offset")
+	if err != nil {
+		return 0, 0, err
+	}
+	if offsetStr != "" {
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("Invalid offset")
+		}
+	}
+	return limit, offset, nil
+}
+
+// handleArtistConcerts serves a page of one artist's concerts in
+// chronological order, selected with limit and offset like
+// handleLocations. The total is sent in X-Total-Count.
+func handleArtistConcerts(w http.ResponseWriter, r *http.Request) {
+
+	if !allowGetHead(w, r) {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	values := r.URL.Query()
+	id, err := parseID(values)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, errInvalidID, err.Error())
+		return
+	}
+	limit, offset, err := parseLimitOffset(values)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, errInvalidParameter, err.Error())
+		return
+	}
+
+	data, err := loadAllData(r.Context())
+	if err != nil {
+		writeFetchError(w, r, err)
+		return
+	}
+
+	if _, found := data.Artist(id); !found {
+		writeJSONError(w, r, http.StatusNotFound, errArtistNotFound, "Artist not found")
+		return
+	}
+	if data.DatesLocations == nil {
+		writeJSONError(w, r, http.StatusServiceUnavailable, errUpstreamUnavailable, "Concert data unavailable")
+		return
+	}
+
+	if notModified(w, r, data.FetchedAt) {
+		return
+	}
+
+	concerts := relationEntries(data.DatesLocations[id])
+	start := min(offset, len(concerts))
+	end := min(start+limit, len(concerts))
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(concerts)))
+	writeJSON(w, r, http.StatusOK, concerts[start:end])
+}
+
+// maxBatchSize caps the ids accepted by /api/artists/batch.
+const maxBatchSize = 1000
+
+// batchFlushEvery is how many artists are written between flushes.
+const batchFlushEvery = 50
+
+// handleArtistsBatch streams the artists with the given ids as a JSON
+// array, encoding and flushing as it goes so clients can start reading
+// before the whole batch is written. Unknown ids are left out.
+func handleArtistsBatch(w http.ResponseWriter, r *http.Request) {
+
+	if !allowGetHead(w, r) {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	s, err := singleParam(r.URL.Query(), "ids")
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, errInvalidParameter, err.Error())
+		return
+	}
+	if s == "" {
+		writeJSONError(w, r, http.StatusBadRequest, errInvalidParameter, "Missing ids")
+		return
+	}
+	ids, err := parseIDList(s)
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, errInvalidID, err.Error())
+		return
+	}
+	if len(ids) > maxBatchSize {
+		writeJSONError(w, r, http.StatusBadRequest, errInvalidParameter, "Too many ids (max "+strconv.Itoa(maxBatchSize)+")")
+		return
+	}
+
+	data, err := loadAllData(r.Context())
+	if err != nil {
+		writeFetchError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	io.WriteString(w, "[")
+	written := 0
+	for _, id := range ids {
+		a, found := data.Artist(id)
+		if !found {
+			continue
+		}
+		if written > 0 {
+			io.WriteString(w, ",")
+		}
+		if err := enc.Encode(a); err != nil {
+			return
+		}
+		written++
+		if written%batchFlushEvery == 0 {
+			rc.Flush()
+		}
+	}
+	io.WriteString(w, "]\n")
+}
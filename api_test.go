@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestArtistGeoJSONFeaturesInLocationOrder(t *testing.T) {
+	newMockUpstream(t)
+	data := loadFixtures(t)
+
+	// pre-geocode every location so no lookup goes out
+	prev := geoCache
+	geoCache = newLRUCache[string, geocodeResult](100)
+	t.Cleanup(func() { geoCache = prev })
+	var want []string
+	for _, location := range slices.Sorted(maps.Keys(data.DatesLocations[1])) {
+		geoCache.Add(location, geocodeResult{coords: Coordinates{Lat: 1, Lon: 2}, ok: true})
+		want = append(want, prettifyLocation(location))
+	}
+	if len(want) < 2 {
+		t.Fatalf("fixture artist 1 has %d locations; the test needs several", len(want))
+	}
+
+	for range 5 {
+		rec := httptest.NewRecorder()
+		withRequestData(http.HandlerFunc(handleArtistGeoJSON)).ServeHTTP(rec,
+			httptest.NewRequest(http.MethodGet, "/api/artist/geojson?id=1", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", rec.Code, rec.Body)
+		}
+
+		var collection GeoJSONFeatureCollection
+		if err := json.Unmarshal(rec.Body.Bytes(), &collection); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, f := range collection.Features {
+			got = append(got, f.Properties.Location)
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("features %q, want %q", got, want)
+		}
+	}
+}
+
+func TestListingLimitsClampAlike(t *testing.T) {
+	endpoints := []struct {
+		path    string
+		handler http.HandlerFunc
+	}{
+		{"/api/locations?", handleLocations},
+		{"/api/artist/concerts?id=1&", handleArtistConcerts},
+		{"/api/artists/page?", handleArtistsPage},
+	}
+	tests := []struct {
+		limit  string
+		status int
+		items  int
+	}{
+		{"0", http.StatusOK, 1},
+		{"-3", http.StatusOK, 1},
+		{"2", http.StatusOK, 2},
+		{"x", http.StatusBadRequest, 0},
+	}
+	for _, e := range endpoints {
+		for _, tt := range tests {
+			target := e.path + "limit=" + tt.limit
+			rec := httptest.NewRecorder()
+			withRequestData(e.handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+			if rec.Code != tt.status {
+				t.Errorf("GET %s: status %d, want %d", target, rec.Code, tt.status)
+				continue
+			}
+			if tt.status != http.StatusOK {
+				continue
+			}
+
+			// /api/artists/page wraps its items; the others are bare lists
+			var items []json.RawMessage
+			var page struct{ Artists []json.RawMessage }
+			if err := json.Unmarshal(rec.Body.Bytes(), &items); err != nil {
+				if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+					t.Fatalf("GET %s: %v", target, err)
+				}
+				items = page.Artists
+			}
+			if len(items) != tt.items {
+				t.Errorf("GET %s: %d items, want %d", target, len(items), tt.items)
+			}
+		}
+	}
+}
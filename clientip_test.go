@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPWalksEveryForwardedForLine(t *testing.T) {
+	prev := cfg.TrustedProxies
+	cfg.TrustedProxies = parseTrustedProxies("10.0.0.0/8")
+	t.Cleanup(func() { cfg.TrustedProxies = prev })
+
+	tests := []struct {
+		remote string
+		xff    []string
+		want   string
+	}{
+		// untrusted connections are taken at their word, headers or not
+		{"203.0.113.9:1234", []string{"198.51.100.1"}, "203.0.113.9"},
+		{"10.0.0.1:1234", []string{"198.51.100.1"}, "198.51.100.1"},
+		// a spoofed first hop is skipped for the last untrusted one
+		{"10.0.0.1:1234", []string{"1.2.3.4, 198.51.100.1, 10.0.0.2"}, "198.51.100.1"},
+		// the inner proxy appended a line instead of extending the first
+		{"10.0.0.1:1234", []string{"1.2.3.4", "198.51.100.1, 10.0.0.2"}, "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.remote
+		for _, v := range tt.xff {
+			r.Header.Add("X-Forwarded-For", v)
+		}
+		if got := clientIP(r); got != tt.want {
+			t.Errorf("clientIP(%s, XFF %q) = %s, want %s", tt.remote, tt.xff, got, tt.want)
+		}
+	}
+}
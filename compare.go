@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+type ComparePageData struct {
+	Columns    []CompareColumn
+	DateLayout string
+}
+
+// CompareColumn is one artist's side of the comparison.
+type CompareColumn struct {
+	Artist    Artist
+	Locations []CompareLocation
+	Dates     []string
+}
+
+// CompareLocation marks the locations every compared artist played.
+type CompareLocation struct {
+	Label  string
+	Common bool
+}
+
+// parseCompareIDs reads the comma-separated ids parameter: two to
+// cfg.MaxCompare positive integers, duplicates dropped.
+func parseCompareIDs(s string) ([]int, error) {
+	ids, err := parseIDList(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) < 2 {
+		return nil, errors.New("Select at least two artists to compare")
+	}
+	if len(ids) > cfg.MaxCompare {
+		return nil, errors.New("Too many artists to compare (max " + strconv.Itoa(cfg.MaxCompare) + ")")
+	}
+	return ids, nil
+}
+
+func handleCompare(w http.ResponseWriter, r *http.Request) {
+
+	defer drainBody(r)
+
+	if !allowGetHead(w, r) {
+		renderError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	s, err := singleParam(r.URL.Query(), "ids")
+	if err != nil {
+		renderError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ids, err := parseCompareIDs(s)
+	if err != nil {
+		renderError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := loadAllData(r.Context())
+	if err != nil {
+		renderError(w, fetchErrorStatus(err), "Failed to fetch artists")
+		return
+	}
+
+	artists := make([]Artist, len(ids))
+	for i, id := range ids {
+		a, found := data.Artist(id)
+		if !found {
+			renderError(w, http.StatusNotFound, "Artist not found")
+			return
+		}
+		artists[i] = a
+	}
+
+	// a location is common when every compared artist played it
+	played := make(map[string]int)
+	for _, id := range ids {
+		for _, l := range data.Locations[id] {
+			played[l]++
+		}
+	}
+
+	pageData := ComparePageData{
+		Columns:    make([]CompareColumn, len(artists)),
+		DateLayout: dateLayoutFor(r),
+	}
+
+	var wg sync.WaitGroup
+	for i, a := range artists {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			col := CompareColumn{Artist: a, Dates: data.Dates[a.ID]}
+			for _, l := range data.Locations[a.ID] {
+				col.Locations = append(col.Locations, CompareLocation{
+					Label:  prettifyLocation(l),
+					Common: played[l] == len(artists),
+				})
+			}
+			pageData.Columns[i] = col
+		}()
+	}
+	wg.Wait()
+
+	if compareTmpl == nil {
+		renderError(w, http.StatusInternalServerError, "Page temporarily unavailable")
+		return
+	}
+	if err := compareTmpl.Execute(w, pageData); err != nil {
+		renderError(w, http.StatusInternalServerError, "Failed to render compare page")
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"log"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds settings read from the environment at startup.
+type Config struct {
+	// APIBaseURL is the upstream API root; point it at a mock server for
+	// benchmarks or local testing.
+	APIBaseURL string
+
+	TemplateDir    string
+	StaticDir      string
+	PrefetchImages bool
+	ImageProxy     bool
+	GeoCacheSize   int
+	ImageCacheSize int
+	PageCacheSize  int
+	Offline        bool
+
+	// MaxUpstreamBytes caps the size of each upstream API response.
+	MaxUpstreamBytes int64
+
+	// Searches shorter than MinQueryLength match everything, or, with
+	// ShortQueryPrompt, show a prompt to type more instead.
+	MinQueryLength   int
+	ShortQueryPrompt bool
+
+	// APIKey, when set, is required to call the /api/ routes.
+	APIKey string
+
+	// CORSOrigin is the Access-Control-Allow-Origin sent on /api/ routes.
+	CORSOrigin string
+
+	// TolerateTemplateErrors keeps the server up when a template fails to
+	// parse; pages using it get a plain error instead.
+	TolerateTemplateErrors bool
+
+	// MaxConcurrentRequests caps the requests served at once; the rest
+	// get a 503.
+	MaxConcurrentRequests int
+
+	// RequestTimeout bounds how long a request may wait on the upstream.
+	RequestTimeout time.Duration
+
+	// The http.Server timeouts, guarding against clients that hold
+	// connections open. WriteTimeout should stay above RequestTimeout so
+	// timed-out requests still get their error response.
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	// LogFile, when set, receives the log output instead of stderr.
+	LogFile string
+
+	// StrictStartup makes an unreachable upstream or a missing static
+	// directory at startup fatal instead of a logged warning.
+	StrictStartup bool
+
+	// StrictParams rejects unknown values of the index's fixed-choice
+	// parameters (sort, view, match, ...) with a 400 instead of ignoring
+	// them.
+	StrictParams bool
+
+	// MaxCompare is the most artists /compare shows side by side.
+	MaxCompare int
+
+	// PrecomputePages builds the artist detail pages on every refresh,
+	// at most MaxPrecomputedPages of them.
+	PrecomputePages     bool
+	MaxPrecomputedPages int
+
+	// TrustedProxies are the proxy addresses whose X-Forwarded-For and
+	// X-Real-IP headers are believed; see clientIP.
+	TrustedProxies []netip.Prefix
+
+	// RateLimit is the requests allowed per client IP per minute; 0
+	// turns rate limiting off.
+	RateLimit int
+
+	// LogRequests logs a line for every request served.
+	LogRequests bool
+
+	// Maintenance starts the server in maintenance mode.
+	Maintenance bool
+
+	// StaleAfter is how long data may go without a successful refresh
+	// before pages flag it as stale and /healthz reports "degraded".
+	StaleAfter time.Duration
+
+	// SearchWeight* score a search term found in an artist's name, a
+	// member's name or a location; results are ranked by the total.
+	SearchWeightName     int
+	SearchWeightMember   int
+	SearchWeightLocation int
+
+	// UserAgent is sent on every outbound request.
+	UserAgent string
+
+	// SavedQueriesFile, when set, persists the saved queries of
+	// /admin/saved across restarts.
+	SavedQueriesFile string
+
+	// BasePath is the path prefix the app is served under, e.g.
+	// "/groupie", or "" at the root. Routes and generated links include it.
+	BasePath string
+
+	// AdminToken enables the /admin/ routes, which require it as a
+	// bearer token.
+	AdminToken string
+}
+
+var cfg Config
+
+func loadConfig() Config {
+	return Config{
+		APIBaseURL: strings.TrimRight(envOr("API_BASE_URL", defaultAPIBaseURL), "/"),
+
+		BasePath:       normalizeBasePath(os.Getenv("BASE_PATH")),
+		UserAgent:      envOr("USER_AGENT", "groupie-tracker/"+version),
+		TemplateDir:    envOr("TEMPLATE_DIR", "templates"),
+		StaticDir:      envOr("STATIC_DIR", "static"),
+		PrefetchImages: envBool("PREFETCH_IMAGES"),
+		ImageProxy:     envBool("IMAGE_PROXY"),
+		GeoCacheSize:   envInt("GEO_CACHE_SIZE", 1000),
+		ImageCacheSize: envInt("IMAGE_CACHE_SIZE", 500),
+		PageCacheSize:  envInt("PAGE_CACHE_SIZE", 200),
+		Offline:        envBool("OFFLINE"),
+
+		MaxUpstreamBytes: int64(envInt("MAX_UPSTREAM_BYTES", 10<<20)),
+		MinQueryLength:   envInt("MIN_QUERY_LENGTH", 2),
+		ShortQueryPrompt: envBool("SHORT_QUERY_PROMPT"),
+
+		SearchWeightName:     envWeight("SEARCH_WEIGHT_NAME", 4),
+		SearchWeightMember:   envWeight("SEARCH_WEIGHT_MEMBER", 2),
+		SearchWeightLocation: envWeight("SEARCH_WEIGHT_LOCATION", 1),
+
+		APIKey:     os.Getenv("API_KEY"),
+		CORSOrigin: envOr("CORS_ORIGIN", "*"),
+
+		TolerateTemplateErrors: envBool("TOLERATE_TEMPLATE_ERRORS"),
+		MaxConcurrentRequests:  envInt("MAX_CONCURRENT_REQUESTS", 100),
+		RequestTimeout:         envDuration("REQUEST_TIMEOUT", 15*time.Second),
+		ReadTimeout:            envDuration("READ_TIMEOUT", 10*time.Second),
+		ReadHeaderTimeout:      envDuration("READ_HEADER_TIMEOUT", 5*time.Second),
+		WriteTimeout:           envDuration("WRITE_TIMEOUT", 30*time.Second),
+		IdleTimeout:            envDuration("IDLE_TIMEOUT", 60*time.Second),
+		LogFile:                os.Getenv("LOG_FILE"),
+		SavedQueriesFile:       os.Getenv("SAVED_QUERIES_FILE"),
+		StrictStartup:          envBool("STRICT_STARTUP"),
+		StrictParams:           envBool("STRICT_PARAMS"),
+		MaxCompare:             envInt("MAX_COMPARE", 4),
+		StaleAfter:             envDuration("STALE_AFTER", 2*cacheTTL),
+		PrecomputePages:        envBool("PRECOMPUTE_PAGES"),
+		MaxPrecomputedPages:    envInt("MAX_PRECOMPUTED_PAGES", 1000),
+		TrustedProxies:         parseTrustedProxies(os.Getenv("TRUSTED_PROXIES")),
+		RateLimit:              envInt("RATE_LIMIT", 0),
+		LogRequests:            envBool("LOG_REQUESTS"),
+		Maintenance:            envBool("MAINTENANCE"),
+		AdminToken:             os.Getenv("ADMIN_TOKEN"),
+	}
+}
+
+// envOr returns the value of the environment variable key, or def when
+// it is unset or empty.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// envBool reports whether the environment variable key is set to a true
+// value such as "1" or "true".
+func envBool(key string) bool {
+	v, _ := strconv.ParseBool(os.Getenv(key))
+	return v
+}
+
+// envInt returns the positive integer value of the environment variable
+// key, or def when it is unset or invalid.
+func envInt(key string, def int) int {
+	n, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || n < 1 {
+		return def
+	}
+	return n
+}
+
+// envWeight returns the non-negative integer value of the environment
+// variable key, or def when it is unset. 0 is allowed, so a search field
+// can be switched off; anything else invalid is logged and ignored.
+func envWeight(key string, def int) int {
+	s := os.Getenv(key)
+	if s == "" {
+		return def
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		log.Printf("Ignoring %s=%q: want a whole number of 0 or more; using %d", key, s, def)
+		return def
+	}
+	return n
+}
+
+// envDuration returns the positive duration (e.g. "15s") in the
+// environment variable key, or def when it is unset or invalid.
+func envDuration(key string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
+
+// normalizeBasePath turns "groupie", "/groupie/" and the like into
+// "/groupie", and "/" or "" into "".
+func normalizeBasePath(p string) string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestEnvWeight(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"", 3},
+		{"0", 0},
+		{"5", 5},
+		{"-1", 3},
+		{"x", 3},
+	}
+	for _, tt := range tests {
+		t.Setenv("TEST_WEIGHT", tt.value)
+		if got := envWeight("TEST_WEIGHT", 3); got != tt.want {
+			t.Errorf("envWeight(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cacheTTL is how long fetched upstream data is reused before refreshing.
+const cacheTTL = 5 * time.Minute
+
+// Dataset bundles everything fetched from the upstream API.
+type Dataset struct {
+	Artists   []Artist
+	Locations map[int][]string
+	Dates     map[int][]string
+	Relation  map[int][]string
+
+	// DatesLocations is the raw relation data: artist ID → location → dates.
+	DatesLocations map[int]map[string][]string
+
+	// ArtistsByID indexes Artists; it is built with the dataset and never
+	// modified afterwards, so readers need no lock.
+	ArtistsByID map[int]Artist
+
+	// ArtistsBySlug maps each artist's URL slug to its ID; see assignSlugs.
+	ArtistsBySlug map[string]int
+
+	// ArtistsByLocation maps each location slug to the sorted IDs of the
+	// artists who played it, built with the dataset like ArtistsByID.
+	ArtistsByLocation map[string][]int
+
+	// LocationIndex lists every distinct location once, sorted by slug.
+	LocationIndex []LocationSummary
+
+	// FetchedAt is when the dataset was fetched from the upstream.
+	FetchedAt time.Time
+
+	// ArtistPages holds prebuilt detail pages by artist ID when
+	// cfg.PrecomputePages is set; artists beyond cfg.MaxPrecomputedPages
+	// are built per request instead.
+	ArtistPages map[int]ArtistPageData
+}
+
+// LocationSummary is one distinct location and the artists who played it.
+type LocationSummary struct {
+	Location string `json:"location"`
+	Label    string `json:"label"`
+	Artists  []int  `json:"artists"`
+}
+
+// Artist looks up an artist by ID.
+func (d *Dataset) Artist(id int) (Artist, bool) {
+	a, ok := d.ArtistsByID[id]
+	return a, ok
+}
+
+// Stale reports whether d is older than cfg.StaleAfter, meaning refreshes
+// have been failing for a while and cached data is being served.
+func (d *Dataset) Stale() bool {
+	return time.Since(d.FetchedAt) > cfg.StaleAfter
+}
+
+var cache struct {
+	sync.Mutex
+	data    *Dataset
+	fetched time.Time
+}
+
+// lastFetched mirrors cache.fetched as Unix nanoseconds (0 before the
+// first fetch) so /healthz can read it without waiting on a refresh.
+var lastFetched atomic.Int64
+
+// requestDataKey is the context key of a request's *requestData.
+type requestDataKey struct{}
+
+// requestData memoises loadAllData for one request, so every part of a
+// handler sees the same dataset even when it wasn't cached (partial data
+// after a failed fetch) and the upstream is hit at most once.
+type requestData struct {
+	once sync.Once
+	data *Dataset
+	err  error
+}
+
+// withRequestData gives each request its own loadAllData memo.
+func withRequestData(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), requestDataKey{}, &requestData{})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// loadAllData returns the request's dataset, loading it on first use.
+// Outside a request (no memo in ctx) it goes straight to the cache.
+func loadAllData(ctx context.Context) (*Dataset, error) {
+	memo, ok := ctx.Value(requestDataKey{}).(*requestData)
+	if !ok {
+		return loadCachedData(ctx)
+	}
+	memo.once.Do(func() {
+		memo.data, memo.err = loadCachedData(ctx)
+	})
+	return memo.data, memo.err
+}
+
+// artistCache holds an artists-only dataset for the views that need
+// nothing else; see loadArtistData.
+var artistCache struct {
+	sync.Mutex
+	data *Dataset
+}
+
+// loadArtistData returns a dataset that is only guaranteed to have the
+// artists, their slugs and ArtistsByID, costing a single upstream call
+// when nothing is cached. A fresh full dataset is returned as is, since
+// it has the artists too. Failures fall back to stale data like
+// loadCachedData.
+func loadArtistData(ctx context.Context) (*Dataset, error) {
+	cache.Lock()
+	full, fetched := cache.data, cache.fetched
+	cache.Unlock()
+	if full != nil && time.Since(fetched) < cacheTTL {
+		return full, nil
+	}
+
+	artistCache.Lock()
+	defer artistCache.Unlock()
+
+	if cached := artistCache.data; cached != nil && time.Since(cached.FetchedAt) < cacheTTL {
+		return cached, nil
+	}
+
+	artists, err := fetchArtists(ctx)
+	if err != nil {
+		if stale := cmp.Or(artistCache.data, full); stale != nil {
+			log.Printf("Refresh failed, serving cached data: %v", err)
+			return stale, nil
+		}
+		log.Printf("Failed to load data: %v", err)
+		return nil, err
+	}
+
+	data := &Dataset{Artists: artists, FetchedAt: time.Now()}
+	indexArtists(data)
+
+	artistCache.data = data
+	lastFetched.Store(data.FetchedAt.UnixNano())
+	return data, nil
+}
+
+// loadCachedData returns the cached dataset, refreshing it from the upstream
+// when it is older than cacheTTL. The lock is held while fetching so
+// concurrent requests wait for a single refresh instead of each hitting
+// the upstream. If a refresh fails, the previous data is served.
+//
+// A refresh runs under the ctx of the request that triggered it; if that
+// request's deadline passes, the next caller starts a fresh attempt.
+func loadCachedData(ctx context.Context) (*Dataset, error) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	if cache.data != nil && time.Since(cache.fetched) < cacheTTL {
+		return cache.data, nil
+	}
+
+	data, err := fetchAll(ctx)
+	if err != nil {
+		if cache.data != nil {
+			log.Printf("Refresh failed, serving cached data: %v", err)
+			return cache.data, nil
+		}
+		if data == nil {
+			log.Printf("Failed to load data: %v", err)
+			return nil, err
+		}
+		// artists loaded but a secondary dataset didn't; serve what we
+		// have without caching it so the next request retries
+		log.Printf("Partial data: %v", err)
+		return data, nil
+	}
+
+	if cfg.PrecomputePages {
+		precomputeArtistPages(data)
+	}
+
+	cache.data = data
+	cache.fetched = data.FetchedAt
+	lastFetched.Store(cache.fetched.UnixNano())
+	return data, nil
+}
+
+// precomputeArtistPages builds the detail page of up to
+// cfg.MaxPrecomputedPages artists so they are served without assembling
+// anything. It runs once per refresh, so the pages never outlive the
+// data they were built from.
+func precomputeArtistPages(data *Dataset) {
+	n := min(len(data.Artists), cfg.MaxPrecomputedPages)
+	data.ArtistPages = make(map[int]ArtistPageData, n)
+	for _, a := range data.Artists[:n] {
+		data.ArtistPages[a.ID] = buildArtistPage(data, a)
+	}
+}
+
+// fetchErrorStatus is the status to answer with when loadAllData fails:
+// 504 when the request ran out of time, 500 otherwise.
+func fetchErrorStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+// indexArtists assigns the artists' slugs and builds ArtistsByID.
+func indexArtists(data *Dataset) {
+	assignSlugs(data)
+
+	data.ArtistsByID = make(map[int]Artist, len(data.Artists))
+	for _, a := range data.Artists {
+		data.ArtistsByID[a.ID] = a
+	}
+}
+
+// fetchAll runs the four upstream fetches concurrently. It returns nil
+// only when the artists themselves could not be fetched; a failure in
+// one of the other datasets is returned alongside the partial data.
+//
+// Each fetch goroutine writes only its own Dataset field and errs slot,
+// and builds its map privately; the derived indexes are built after
+// wg.Wait, so nothing is shared while the fetches run.
+func fetchAll(ctx context.Context) (*Dataset, error) {
+	var (
+		wg   sync.WaitGroup
+		data Dataset
+		errs [4]error
+	)
+	data.FetchedAt = time.Now()
+
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		data.Artists, errs[0] = fetchArtists(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		data.Locations, errs[1] = fetchLocations(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		data.Dates, errs[2] = fetchDates(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		data.DatesLocations, errs[3] = fetchRelation(ctx)
+	}()
+	wg.Wait()
+
+	data.Relation = make(map[int][]string, len(data.DatesLocations))
+	for id, dl := range data.DatesLocations {
+		data.Relation[id] = formatRelation(dl)
+	}
+
+	data.ArtistsByLocation = make(map[string][]int)
+	for id, locations := range data.Locations {
+		for _, l := range locations {
+			data.ArtistsByLocation[l] = append(data.ArtistsByLocation[l], id)
+		}
+	}
+	data.LocationIndex = make([]LocationSummary, 0, len(data.ArtistsByLocation))
+	for l, ids := range data.ArtistsByLocation {
+		sort.Ints(ids)
+		data.LocationIndex = append(data.LocationIndex, LocationSummary{Location: l, Label: prettifyLocation(l), Artists: ids})
+	}
+	sort.Slice(data.LocationIndex, func(i, j int) bool {
+		return data.LocationIndex[i].Location < data.LocationIndex[j].Location
+	})
+
+	if errs[0] != nil {
+		return nil, errs[0]
+	}
+
+	indexArtists(&data)
+
+	for _, err := range errs[1:] {
+		if err != nil {
+			return &data, err
+		}
+	}
+	return &data, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoadCachedDataCoalescesColdLoads(t *testing.T) {
+	upstream := newMockUpstream(t)
+	upstream.delay = 50 * time.Millisecond
+
+	const callers = 20
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make(chan error, callers)
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if _, err := loadCachedData(context.Background()); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("loadCachedData: %v", err)
+	}
+	for _, endpoint := range upstreamEndpoints {
+		if n := upstream.Hits(endpoint); n != 1 {
+			t.Errorf("%s fetched %d times by %d concurrent cold loads, want 1", endpoint, n, callers)
+		}
+	}
+}
+
+// TestFetchAllRepeated is mostly for `go test -race`: fetchAll's four
+// fetch goroutines must not share anything while they run.
+func TestFetchAllRepeated(t *testing.T) {
+	newMockUpstream(t)
+
+	for i := range 20 {
+		data, err := fetchAll(context.Background())
+		if err != nil {
+			t.Fatalf("fetch %d: %v", i, err)
+		}
+		if len(data.Artists) == 0 || data.Locations == nil || data.Dates == nil || data.DatesLocations == nil {
+			t.Fatalf("fetch %d: incomplete dataset", i)
+		}
+	}
+}
+
+// refreshedData loads the dataset from a mock upstream, then expires it
+// and loads it again, returning the refreshed copy.
+func refreshedData(t *testing.T) *Dataset {
+	t.Helper()
+	newMockUpstream(t)
+
+	first, err := loadCachedData(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Lock()
+	cache.fetched = cache.fetched.Add(-cacheTTL)
+	cache.Unlock()
+
+	data, err := loadCachedData(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data == first {
+		t.Fatal("expired cache was not refreshed")
+	}
+	return data
+}
+
+func TestArtistsByIDMatchesArtistsAfterRefresh(t *testing.T) {
+	data := refreshedData(t)
+
+	if len(data.ArtistsByID) != len(data.Artists) {
+		t.Errorf("ArtistsByID has %d entries for %d artists", len(data.ArtistsByID), len(data.Artists))
+	}
+	for _, a := range data.Artists {
+		got, ok := data.Artist(a.ID)
+		if !ok {
+			t.Errorf("artist %d missing from ArtistsByID", a.ID)
+			continue
+		}
+		if !reflect.DeepEqual(got, a) {
+			t.Errorf("ArtistsByID[%d] = %+v, want %+v", a.ID, got, a)
+		}
+	}
+}
+
+func TestArtistsByLocationAfterRefresh(t *testing.T) {
+	data := refreshedData(t)
+
+	// brute force: scan every artist's locations for each location
+	want := make(map[string][]int)
+	for _, a := range data.Artists {
+		for _, l := range data.Locations[a.ID] {
+			if !slices.Contains(want[l], a.ID) {
+				want[l] = append(want[l], a.ID)
+			}
+		}
+	}
+	for _, ids := range want {
+		slices.Sort(ids)
+	}
+
+	if !reflect.DeepEqual(data.ArtistsByLocation, want) {
+		t.Errorf("ArtistsByLocation = %v\nwant %v", data.ArtistsByLocation, want)
+	}
+}
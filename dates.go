@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// concertDateLayout is the upstream date format, e.g. "23-08-2019".
+const concertDateLayout = "02-01-2006"
+
+// defaultDateLayout is used when the visitor's language is unknown; the
+// spelled-out month keeps it unambiguous.
+const defaultDateLayout = "02 Jan 2006"
+
+// dateLayouts maps lowercase language tags to display layouts. Region
+// tags are checked before their primary language.
+var dateLayouts = map[string]string{
+	"en-us": "01/02/2006",
+	"en-gb": "02/01/2006",
+	"en-au": "02/01/2006",
+	"en-nz": "02/01/2006",
+	"en-ie": "02/01/2006",
+	"fr":    "02/01/2006",
+	"es":    "02/01/2006",
+	"it":    "02/01/2006",
+	"pt":    "02/01/2006",
+	"de":    "02.01.2006",
+	"ru":    "02.01.2006",
+	"ja":    "2006/01/02",
+	"zh":    "2006/01/02",
+	"ko":    "2006. 01. 02.",
+}
+
+// parseConcertDate parses an upstream date, ignoring surrounding
+// whitespace and the leading "*" some entries carry. Every feature that
+// interprets dates (filters, sorting, the timeline, display) goes
+// through here so they agree on what counts as a valid date.
+func parseConcertDate(s string) (time.Time, error) {
+	trimmed := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), "*"))
+	if trimmed == "" {
+		return time.Time{}, errors.New("empty date")
+	}
+	t, err := time.Parse(concertDateLayout, trimmed)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", s)
+	}
+	return t, nil
+}
+
+// dateLayoutFor picks a date layout from the "lang" cookie, falling back
+// to the first recognised language in the Accept-Language header.
+func dateLayoutFor(r *http.Request) string {
+	if c, err := r.Cookie("lang"); err == nil {
+		if layout, ok := lookupDateLayout(c.Value); ok {
+			return layout
+		}
+	}
+
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, _, _ := strings.Cut(part, ";")
+		if layout, ok := lookupDateLayout(tag); ok {
+			return layout
+		}
+	}
+	return defaultDateLayout
+}
+
+func lookupDateLayout(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimSpace(strings.ReplaceAll(tag, "_", "-")))
+	if layout, ok := dateLayouts[tag]; ok {
+		return layout, true
+	}
+	primary, _, _ := strings.Cut(tag, "-")
+	layout, ok := dateLayouts[primary]
+	return layout, ok
+}
+
+// formatDate renders an upstream date with the given layout, returning
+// the input unchanged if it can't be parsed.
+func formatDate(layout, s string) string {
+	t, err := parseConcertDate(s)
+	if err != nil {
+		return s
+	}
+	return t.Format(layout)
+}
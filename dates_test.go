@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseConcertDate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr string
+	}{
+		{in: "", wantErr: "empty date"},
+		{in: "*23-08-2019", want: time.Date(2019, time.August, 23, 0, 0, 0, 0, time.UTC)},
+		{in: "32-01-2020", wantErr: `invalid date "32-01-2020"`},
+		{in: "garbage", wantErr: `invalid date "garbage"`},
+	}
+	for _, tt := range tests {
+		got, err := parseConcertDate(tt.in)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("parseConcertDate(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseConcertDate(%q): %v", tt.in, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseConcertDate(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"time"
+)
+
+// relationsFlushEvery is how many CSV rows handleRelationsCSV writes
+// between flushes.
+const relationsFlushEvery = 500
+
+// handleRelationsCSV serves /export/relations.csv: one artist, date,
+// location row per concert across all artists, in artist order and then
+// chronologically. Dates are written as YYYY-MM-DD and locations
+// prettified; dates that don't parse are skipped. Rows are streamed as
+// they are encoded rather than built up in memory.
+func handleRelationsCSV(w http.ResponseWriter, r *http.Request) {
+
+	defer drainBody(r)
+
+	if !allowGetHead(w, r) {
+		renderError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	data, err := loadAllData(r.Context())
+	if err != nil {
+		renderError(w, fetchErrorStatus(err), "Failed to fetch artists")
+		return
+	}
+	if data.DatesLocations == nil {
+		renderError(w, http.StatusServiceUnavailable, "Concert data unavailable")
+		return
+	}
+
+	if notModified(w, r, data.FetchedAt) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="relations.csv"`)
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"artist", "date", "location"})
+	rows := 0
+	for _, a := range data.Artists {
+		for _, e := range relationEntries(data.DatesLocations[a.ID]) {
+			t, err := parseConcertDate(e.Date)
+			if err != nil {
+				continue
+			}
+			cw.Write([]string{a.Name, t.Format(time.DateOnly), prettifyLocation(e.Location)})
+			rows++
+			if rows%relationsFlushEvery == 0 {
+				cw.Flush()
+				if cw.Error() != nil {
+					return
+				}
+				rc.Flush()
+			}
+		}
+	}
+	cw.Flush()
+}
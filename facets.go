@@ -0,0 +1,144 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+)
+
+// FacetOptions lists the values the location and year filters can take
+// in the current dataset.
+type FacetOptions struct {
+	Locations []FacetOption
+	Years     []int
+
+	// Letters are the name initials present, A–Z order with "#" last
+	Letters []string
+}
+
+type FacetOption struct {
+	Value string
+	Label string
+}
+
+func buildFacetOptions(data *Dataset) FacetOptions {
+	var opts FacetOptions
+
+	for l := range data.ArtistsByLocation {
+		opts.Locations = append(opts.Locations, FacetOption{Value: l, Label: prettifyLocation(l)})
+	}
+	// distinct slugs can share a label; break ties by slug, since map
+	// order would otherwise decide
+	sort.Slice(opts.Locations, func(i, j int) bool {
+		if opts.Locations[i].Label != opts.Locations[j].Label {
+			return opts.Locations[i].Label < opts.Locations[j].Label
+		}
+		return opts.Locations[i].Value < opts.Locations[j].Value
+	})
+
+	seenYear := make(map[int]bool)
+	for _, a := range data.Artists {
+		if a.CreationDate > 0 && !seenYear[a.CreationDate] {
+			seenYear[a.CreationDate] = true
+			opts.Years = append(opts.Years, a.CreationDate)
+		}
+	}
+	sort.Ints(opts.Years)
+
+	seenLetter := make(map[string]bool)
+	for _, a := range data.Artists {
+		seenLetter[nameInitial(a.Name)] = true
+	}
+	for l := range seenLetter {
+		opts.Letters = append(opts.Letters, l)
+	}
+	sort.Slice(opts.Letters, func(i, j int) bool {
+		// "#" sorts before letters byte-wise but belongs at the end
+		if opts.Letters[i] == "#" || opts.Letters[j] == "#" {
+			return opts.Letters[j] == "#"
+		}
+		return opts.Letters[i] < opts.Letters[j]
+	})
+
+	return opts
+}
+
+// FilterOptions is the facet metadata served by /api/filters: every
+// value each filter can take in the dataset and how many artists have it.
+type FilterOptions struct {
+	Members   []FilterCount `json:"members"`
+	Years     []YearCount   `json:"years"`
+	Locations []FilterCount `json:"locations"`
+	Decades   []YearCount   `json:"decades"`
+}
+
+type FilterCount struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+type YearCount struct {
+	Value int `json:"value"`
+	Count int `json:"count"`
+}
+
+func buildFilterOptions(data *Dataset) FilterOptions {
+	opts := FilterOptions{
+		Members:   []FilterCount{},
+		Years:     []YearCount{},
+		Locations: []FilterCount{},
+		Decades:   []YearCount{},
+	}
+
+	// the members buckets match the index checkboxes: 0-4 exact, then
+	// 5 or more
+	var members [6]int
+	years := make(map[int]int)
+	decades := make(map[int]int)
+	for _, a := range data.Artists {
+		members[min(len(a.Members), 5)]++
+		if a.CreationDate > 0 {
+			years[a.CreationDate]++
+		}
+		if t, err := parseConcertDate(a.FirstAlbum); err == nil {
+			decades[t.Year()/10*10]++
+		}
+	}
+
+	for i, n := range members {
+		if n == 0 {
+			continue
+		}
+		mr := MemberRange{Min: i, Max: i}
+		label := strconv.Itoa(i)
+		if i == 5 {
+			mr.Max = -1
+			label = "5+"
+		}
+		opts.Members = append(opts.Members, FilterCount{Value: mr.String(), Label: label, Count: n})
+	}
+
+	opts.Years = yearCounts(years)
+	opts.Decades = yearCounts(decades)
+
+	for l, ids := range data.ArtistsByLocation {
+		opts.Locations = append(opts.Locations, FilterCount{Value: l, Label: prettifyLocation(l), Count: len(ids)})
+	}
+	sort.Slice(opts.Locations, func(i, j int) bool {
+		if opts.Locations[i].Label != opts.Locations[j].Label {
+			return opts.Locations[i].Label < opts.Locations[j].Label
+		}
+		return opts.Locations[i].Value < opts.Locations[j].Value
+	})
+
+	return opts
+}
+
+func yearCounts(counts map[int]int) []YearCount {
+	result := make([]YearCount, 0, len(counts))
+	for y, n := range counts {
+		result = append(result, YearCount{Value: y, Count: n})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Value < result[j].Value })
+	return result
+}
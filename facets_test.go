@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Every facet value /api/filters offers must count exactly the artists
+// the index lists with that filter, and only it, applied.
+func TestFilterOptionsMatchFilterArtists(t *testing.T) {
+	data := loadFixtures(t)
+	opts := buildFilterOptions(data)
+
+	check := func(name, value string, count int) {
+		t.Helper()
+		p, err := parseIndexParams(url.Values{name: {value}})
+		if err != nil {
+			t.Fatalf("%s=%s: %v", name, value, err)
+		}
+		if n := len(filterArtists(data, p, time.Now())); n != count {
+			t.Errorf("%s=%s: facet counts %d artists, filter lists %d", name, value, count, n)
+		}
+	}
+
+	if len(opts.Members) == 0 || len(opts.Years) == 0 || len(opts.Locations) == 0 {
+		t.Fatalf("fixtures give empty facets: %+v", opts)
+	}
+	for _, o := range opts.Members {
+		check("members", o.Value, o.Count)
+	}
+	for _, o := range opts.Years {
+		check("year", strconv.Itoa(o.Value), o.Count)
+	}
+	for _, o := range opts.Locations {
+		check("location", o.Value, o.Count)
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// filterArtists applies the search and every active filter in p. Both the
+// HTML index and the JSON listing go through here so they always agree.
+//
+// The text search and the startsWith initial always narrow the results.
+// The facet filters (members, location, year, timeframe, complete) are
+// combined according to p.Match: "all" keeps artists passing every
+// active facet, "any" keeps those passing at least one.
+func filterArtists(data *Dataset, p IndexParams, now time.Time) []Artist {
+	var filtered []Artist
+	if len(p.Terms) > 0 && !p.QueryTooShort {
+		filtered = filterByTerms(data.Artists, data.Locations, p.Terms, p.TermMode)
+	} else {
+		filtered = data.Artists
+	}
+
+	if p.StartsWith != "" {
+		filtered = filterByInitial(filtered, p.StartsWith)
+	}
+
+	var facets []func([]Artist) []Artist
+	if len(p.Members) > 0 {
+		facets = append(facets, func(as []Artist) []Artist { return filterByMembers(as, p.Members) })
+	}
+	if p.Location != "" {
+		facets = append(facets, func(as []Artist) []Artist { return filterByLocation(as, data.ArtistsByLocation[p.Location]) })
+	}
+	if p.Year != 0 {
+		facets = append(facets, func(as []Artist) []Artist { return filterByYear(as, p.Year) })
+	}
+	if p.Timeframe != "" {
+		facets = append(facets, func(as []Artist) []Artist { return filterByTimeframe(as, data.Dates, p.Timeframe, now) })
+	}
+	if p.Complete {
+		facets = append(facets, func(as []Artist) []Artist { return filterComplete(as, data) })
+	}
+
+	if p.Match != "any" || len(facets) == 0 {
+		for _, facet := range facets {
+			filtered = facet(filtered)
+		}
+		return sortArtists(filtered, data, p.Sort)
+	}
+
+	matched := make(map[int]bool)
+	for _, facet := range facets {
+		for _, a := range facet(filtered) {
+			matched[a.ID] = true
+		}
+	}
+	var result []Artist
+	for _, a := range filtered {
+		if matched[a.ID] {
+			result = append(result, a)
+		}
+	}
+	return sortArtists(result, data, p.Sort)
+}
+
+// sortArtists orders artists by mode: "concerts" puts those with the most
+// date-location pairs in data.DatesLocations first, ties by ID; any other
+// mode leaves them as they are. The slice is copied
+// before sorting since it may be data.Artists itself.
+func sortArtists(artists []Artist, data *Dataset, mode string) []Artist {
+	if mode != "concerts" {
+		return artists
+	}
+	counts := make(map[int]int, len(artists))
+	for _, a := range artists {
+		counts[a.ID] = concertCount(data.DatesLocations[a.ID])
+	}
+	sorted := slices.Clone(artists)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if ci, cj := counts[sorted[i].ID], counts[sorted[j].ID]; ci != cj {
+			return ci > cj
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	return sorted
+}
+
+// concertCount is the number of date-location pairs in an artist's
+// relation data.
+func concertCount(dl map[string][]string) int {
+	n := 0
+	for _, dates := range dl {
+		n += len(dates)
+	}
+	return n
+}
+
+// filterByTerms keeps the artists matching all (mode "all") or any (mode
+// "any") of the terms, compared after normalizeSearch so accents and
+// punctuation don't matter. A term matches when it appears in the
+// artist's name, a member's name or a location. Each field a term
+// matches adds its weight from cfg (name, then member, then location by
+// default), and the artists with the highest total come first; ties are
+// ordered by ID so pages don't shift between refreshes.
+func filterByTerms(artists []Artist, locations map[int][]string, terms []string, mode string) []Artist {
+	var normalized []string
+	for _, t := range terms {
+		if t = normalizeSearch(t); t != "" {
+			normalized = append(normalized, t)
+		}
+	}
+	if len(normalized) == 0 {
+		return artists
+	}
+
+	var result []Artist
+	scores := make(map[int]int)
+	for _, a := range artists {
+		name := normalizeSearch(a.Name)
+		members := make([]string, len(a.Members))
+		for i, m := range a.Members {
+			members[i] = normalizeSearch(m)
+		}
+		locs := make([]string, len(locations[a.ID]))
+		for i, l := range locations[a.ID] {
+			locs[i] = normalizeSearch(prettifyLocation(l))
+		}
+
+		matched, score := 0, 0
+		for _, t := range normalized {
+			s := 0
+			if strings.Contains(name, t) {
+				s += cfg.SearchWeightName
+			}
+			if containsTerm(members, t) {
+				s += cfg.SearchWeightMember
+			}
+			if containsTerm(locs, t) {
+				s += cfg.SearchWeightLocation
+			}
+			if s > 0 {
+				matched++
+				score += s
+			}
+		}
+		if matched == len(normalized) || (mode == "any" && matched > 0) {
+			result = append(result, a)
+			scores[a.ID] = score
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if si, sj := scores[result[i].ID], scores[result[j].ID]; si != sj {
+			return si > sj
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// containsTerm reports whether any of fields contains t.
+func containsTerm(fields []string, t string) bool {
+	for _, f := range fields {
+		if strings.Contains(f, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterByInitial keeps the artists whose nameInitial is initial.
+func filterByInitial(artists []Artist, initial string) []Artist {
+	var result []Artist
+	for _, a := range artists {
+		if nameInitial(a.Name) == initial {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// nameInitial is the upper-cased first letter of name, or "#" when the
+// name starts with anything other than a letter.
+func nameInitial(name string) string {
+	r, _ := utf8.DecodeRuneInString(strings.TrimSpace(name))
+	if !unicode.IsLetter(r) {
+		return "#"
+	}
+	return string(unicode.ToUpper(r))
+}
+
+// filterByMembers keeps the artists whose member count falls in any of
+// the ranges. A nil Members slice counts as zero members.
+func filterByMembers(artists []Artist, ranges []MemberRange) []Artist {
+	var result []Artist
+	for _, a := range artists {
+		for _, mr := range ranges {
+			if mr.Contains(len(a.Members)) {
+				result = append(result, a)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// filterByTimeframe keeps the artists with at least one concert in the
+// past ("past") or on or after today ("upcoming"). Dates that can't be
+// parsed are ignored.
+func filterByTimeframe(artists []Artist, dates map[int][]string, timeframe string, now time.Time) []Artist {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var result []Artist
+	for _, a := range artists {
+		for _, d := range dates[a.ID] {
+			t, err := parseConcertDate(d)
+			if err != nil {
+				continue
+			}
+			upcoming := !t.Before(today)
+			if upcoming == (timeframe == "upcoming") {
+				result = append(result, a)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// filterComplete keeps the artists that have locations, dates and
+// relation entries, for spotting gaps in the upstream data.
+func filterComplete(artists []Artist, data *Dataset) []Artist {
+	var result []Artist
+	for _, a := range artists {
+		if len(data.Locations[a.ID]) > 0 && len(data.Dates[a.ID]) > 0 && len(data.Relation[a.ID]) > 0 {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// filterByLocation keeps the artists whose IDs are in ids, the
+// ArtistsByLocation entry for the selected location.
+func filterByLocation(artists []Artist, ids []int) []Artist {
+	played := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		played[id] = true
+	}
+
+	var result []Artist
+	for _, a := range artists {
+		if played[a.ID] {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// filterByYear keeps the artists formed in the given year.
+func filterByYear(artists []Artist, year int) []Artist {
+	var result []Artist
+	for _, a := range artists {
+		if a.CreationDate == year {
+			result = append(result, a)
+		}
+	}
+	return result
+}
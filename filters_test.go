@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math/rand/v2"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+)
+
+// shuffledArtists returns n differently shuffled copies of artists.
+func shuffledArtists(artists []Artist, n int) [][]Artist {
+	rng := rand.New(rand.NewPCG(1, 2))
+	orders := make([][]Artist, n)
+	for i := range orders {
+		orders[i] = slices.Clone(artists)
+		rng.Shuffle(len(orders[i]), func(a, b int) {
+			orders[i][a], orders[i][b] = orders[i][b], orders[i][a]
+		})
+	}
+	return orders
+}
+
+func artistIDs(artists []Artist) []int {
+	ids := make([]int, len(artists))
+	for i, a := range artists {
+		ids[i] = a.ID
+	}
+	return ids
+}
+
+func TestSortTiesAreDeterministic(t *testing.T) {
+	data := loadFixtures(t)
+
+	// with every count equal, sort=concerts may only order by ID
+	tied := *data
+	tied.DatesLocations = map[int]map[string][]string{}
+	want := artistIDs(data.Artists)
+	slices.Sort(want)
+	for _, artists := range shuffledArtists(data.Artists, 10) {
+		if got := artistIDs(sortArtists(artists, &tied, "concerts")); !slices.Equal(got, want) {
+			t.Errorf("sort=concerts with equal counts: %v, want %v", got, want)
+		}
+	}
+
+	// "usa" is a location match, the same score, for several artists
+	var first []int
+	for _, artists := range shuffledArtists(data.Artists, 10) {
+		got := artistIDs(filterByTerms(artists, data.Locations, []string{"usa"}, "all"))
+		if len(got) < 2 {
+			t.Fatalf(`q=usa matches %v; the fixtures need several equal scores`, got)
+		}
+		if first == nil {
+			first = got
+		} else if !slices.Equal(got, first) {
+			t.Errorf("q=usa ranked %v, earlier %v", got, first)
+		}
+	}
+	if !slices.IsSorted(first) {
+		t.Errorf("q=usa ties ranked %v, want ID order", first)
+	}
+}
+
+func TestMembersZeroMatchesArtistsWithoutMembers(t *testing.T) {
+	data := &Dataset{Artists: []Artist{
+		{ID: 1, Name: "Nobody", Members: nil},
+		{ID: 2, Name: "Empty", Members: []string{}},
+		{ID: 3, Name: "Duo", Members: []string{"A", "B"}},
+	}}
+	indexArtists(data)
+
+	p, err := parseIndexParams(url.Values{"members": {"0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := artistIDs(filterArtists(data, p, time.Now())); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("members=0 lists %v, want [1 2]", got)
+	}
+}
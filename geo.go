@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const geocodeURL = "https://nominatim.openstreetmap.org/search"
+
+var geoClient = &http.Client{Timeout: 10 * time.Second}
+
+// geocodeInterval spaces out lookups to Nominatim, whose usage policy
+// allows at most one request per second.
+const geocodeInterval = time.Second
+
+// geoThrottle hands out lookup slots geocodeInterval apart; next is the
+// earliest time the next lookup may start.
+var geoThrottle struct {
+	sync.Mutex
+	next time.Time
+}
+
+// waitGeocodeTurn blocks until this caller's lookup slot comes up, or
+// returns ctx's error if it is done first.
+func waitGeocodeTurn(ctx context.Context) error {
+	geoThrottle.Lock()
+	now := time.Now()
+	slot := geoThrottle.next
+	if slot.Before(now) {
+		slot = now
+	}
+	geoThrottle.next = slot.Add(geocodeInterval)
+	geoThrottle.Unlock()
+
+	timer := time.NewTimer(time.Until(slot))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Coordinates is a geocoded point.
+type Coordinates struct {
+	Lat float64
+	Lon float64
+}
+
+type geocodeResult struct {
+	coords Coordinates
+	ok     bool
+}
+
+// geoCache remembers both hits and misses so a location is only looked
+// up again once it has been evicted. It is sized from config in main.
+var geoCache *lruCache[string, geocodeResult]
+
+// geocode resolves an upstream location slug to coordinates. The second
+// return value is false when the location could not be resolved, and
+// always in offline mode, which makes no lookups.
+func geocode(ctx context.Context, slug string) (Coordinates, bool) {
+	if cfg.Offline {
+		return Coordinates{}, false
+	}
+	if res, cached := geoCache.Get(slug); cached {
+		return res.coords, res.ok
+	}
+
+	coords, ok, err := lookupCoordinates(ctx, prettifyLocation(slug))
+	if err != nil {
+		// don't cache transport errors, the next call may succeed
+		return Coordinates{}, false
+	}
+
+	geoCache.Add(slug, geocodeResult{coords: coords, ok: ok})
+	return coords, ok
+}
+
+func lookupCoordinates(ctx context.Context, query string) (Coordinates, bool, error) {
+	u := geocodeURL + "?" + url.Values{
+		"q":      {query},
+		"format": {"json"},
+		"limit":  {"1"},
+	}.Encode()
+
+	if err := waitGeocodeTurn(ctx); err != nil {
+		return Coordinates{}, false, err
+	}
+
+	// nominatim rejects requests without an identifying user agent,
+	// which newOutboundRequest sets
+	req, err := newOutboundRequest(ctx, u)
+	if err != nil {
+		return Coordinates{}, false, err
+	}
+
+	resp, err := geoClient.Do(req)
+	if err != nil {
+		return Coordinates{}, false, err
+	}
+	defer resp.Body.Close()
+
+	var results []struct {
+		Lat string `json:"lat"`
+		Lon string `json:"lon"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return Coordinates{}, false, err
+	}
+	if len(results) == 0 {
+		return Coordinates{}, false, nil
+	}
+
+	lat, err1 := strconv.ParseFloat(results[0].Lat, 64)
+	lon, err2 := strconv.ParseFloat(results[0].Lon, 64)
+	if err1 != nil || err2 != nil {
+		return Coordinates{}, false, nil
+	}
+	return Coordinates{Lat: lat, Lon: lon}, true, nil
+}
@@ -1,3 +1,5 @@
 module groupie
 
 go 1.25.0
+
+require golang.org/x/text v0.35.0
@@ -0,0 +1,62 @@
+package main
+
+// NameSegment is a piece of an artist name; Match marks the parts that
+// matched the search query. The template escapes each Text itself, so
+// wrapping matches in <mark> can't inject markup.
+type NameSegment struct {
+	Text  string
+	Match bool
+}
+
+// highlightSegments splits name around every case- and accent-insensitive,
+// non-overlapping occurrence of any of the terms, preferring the longest
+// term at each position. Matching is done on runes so offsets stay
+// correct for non-ASCII names.
+func highlightSegments(name string, terms []string) []NameSegment {
+	nameRunes := []rune(name)
+	var termRunes [][]rune
+	for _, t := range terms {
+		if t != "" {
+			termRunes = append(termRunes, []rune(t))
+		}
+	}
+	if len(termRunes) == 0 {
+		return []NameSegment{{Text: name}}
+	}
+
+	var segments []NameSegment
+	last := 0
+	for i := 0; i < len(nameRunes); {
+		n := 0
+		for _, t := range termRunes {
+			if len(t) > n && i+len(t) <= len(nameRunes) && runesEqualFold(nameRunes[i:i+len(t)], t) {
+				n = len(t)
+			}
+		}
+		if n == 0 {
+			i++
+			continue
+		}
+		if i > last {
+			segments = append(segments, NameSegment{Text: string(nameRunes[last:i])})
+		}
+		end := i + n
+		segments = append(segments, NameSegment{Text: string(nameRunes[i:end]), Match: true})
+		i, last = end, end
+	}
+	if last < len(nameRunes) {
+		segments = append(segments, NameSegment{Text: string(nameRunes[last:])})
+	}
+	return segments
+}
+
+// runesEqualFold compares rune by rune ignoring case and accents, so a
+// search for "motorhead" still highlights "Motörhead".
+func runesEqualFold(a, b []rune) bool {
+	for i := range a {
+		if foldRune(a[i]) != foldRune(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	maxImageBytes         = 5 << 20
+	imageFetchConcurrency = 4
+	imagePrefetchDelay    = 2 * time.Second
+)
+
+var imageClient = &http.Client{Timeout: 15 * time.Second}
+
+type cachedImage struct {
+	contentType string
+	body        []byte
+}
+
+// imageCache holds downloaded images by URL. It is sized from config in
+// main.
+var imageCache *lruCache[string, cachedImage]
+
+// imageSem caps how many image downloads run at once, shared by the
+// proxy and the prefetcher.
+var imageSem = make(chan struct{}, imageFetchConcurrency)
+
+// imagePrefetch collapses bursts of index loads into a single pending
+// prefetch run.
+var imagePrefetch struct {
+	sync.Mutex
+	pending bool
+}
+
+// fetchImage returns the image at url, downloading and caching it on a
+// miss. Waiting for a download slot and the download itself both stop
+// when ctx is done.
+func fetchImage(ctx context.Context, url string) (cachedImage, error) {
+	if img, ok := imageCache.Get(url); ok {
+		return img, nil
+	}
+
+	select {
+	case imageSem <- struct{}{}:
+	case <-ctx.Done():
+		return cachedImage{}, ctx.Err()
+	}
+	defer func() { <-imageSem }()
+
+	req, err := newOutboundRequest(ctx, url)
+	if err != nil {
+		return cachedImage{}, err
+	}
+	resp, err := imageClient.Do(req)
+	if err != nil {
+		return cachedImage{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return cachedImage{}, fmt.Errorf("image %s: status %d", url, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
+	if err != nil {
+		return cachedImage{}, err
+	}
+	if len(body) > maxImageBytes {
+		return cachedImage{}, fmt.Errorf("image %s: larger than %d bytes", url, maxImageBytes)
+	}
+
+	img := cachedImage{contentType: resp.Header.Get("Content-Type"), body: body}
+	if img.contentType == "" {
+		img.contentType = http.DetectContentType(body)
+	}
+
+	imageCache.Add(url, img)
+
+	return img, nil
+}
+
+// prefetchImages schedules a background download of every artist image
+// not yet cached. It does nothing unless PREFETCH_IMAGES is enabled, and
+// calls made while a run is pending are dropped.
+func prefetchImages(artists []Artist) {
+	if !cfg.PrefetchImages || cfg.Offline {
+		return
+	}
+
+	imagePrefetch.Lock()
+	if imagePrefetch.pending {
+		imagePrefetch.Unlock()
+		return
+	}
+	imagePrefetch.pending = true
+	imagePrefetch.Unlock()
+
+	time.AfterFunc(imagePrefetchDelay, func() {
+		defer func() {
+			imagePrefetch.Lock()
+			imagePrefetch.pending = false
+			imagePrefetch.Unlock()
+		}()
+
+		var wg sync.WaitGroup
+		for _, a := range artists {
+			if a.Image == "" {
+				continue
+			}
+			if _, ok := imageCache.Get(a.Image); ok {
+				continue
+			}
+			wg.Add(1)
+			go func(url string) {
+				defer wg.Done()
+				if _, err := fetchImage(context.Background(), url); err != nil {
+					log.Printf("Image prefetch failed: %v", err)
+				}
+			}(a.Image)
+		}
+		wg.Wait()
+	})
+}
+
+func handleImage(w http.ResponseWriter, r *http.Request) {
+
+	if !allowGetHead(w, r) {
+		renderError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	id, err := parseID(r.URL.Query())
+	if err != nil {
+		renderError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := loadArtistData(r.Context())
+	if err != nil {
+		renderError(w, fetchErrorStatus(err), "Failed to fetch artists")
+		return
+	}
+
+	artist, found := data.Artist(id)
+	if !found || artist.Image == "" {
+		renderError(w, http.StatusNotFound, "Image not found")
+		return
+	}
+
+	// offline mode makes no image downloads
+	if cfg.Offline {
+		http.Redirect(w, r, urlFor(placeholderImage), http.StatusFound)
+		return
+	}
+
+	img, err := fetchImage(r.Context(), artist.Image)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			renderError(w, http.StatusGatewayTimeout, "Timed out fetching image")
+			return
+		}
+		renderError(w, http.StatusBadGateway, "Failed to fetch image")
+		return
+	}
+
+	w.Header().Set("Content-Type", img.contentType)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Write(img.body)
+}
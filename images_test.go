@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFetchImageGivesUpWaitingForASlot(t *testing.T) {
+	for range imageFetchConcurrency {
+		imageSem <- struct{}{}
+	}
+	defer func() {
+		for range imageFetchConcurrency {
+			<-imageSem
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := fetchImage(ctx, "http://example.invalid/busy.jpeg"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("fetchImage with every slot taken: %v, want a deadline error", err)
+	}
+}
+
+func TestFetchImageStopsWithItsContext(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slow.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := fetchImage(ctx, slow.URL+"/slow.jpeg"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("fetchImage from a stalled server: %v, want a deadline error", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("fetchImage took %v to notice its deadline", elapsed)
+	}
+}
+
+func TestGeocodeStopsWithItsContext(t *testing.T) {
+	prev := cfg.Offline
+	cfg.Offline = false
+	t.Cleanup(func() { cfg.Offline = prev })
+
+	// an expired context must not reach the network, nor be cached as a
+	// miss
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ok := geocode(ctx, "nowhere-test"); ok {
+		t.Error("geocode with a cancelled context found coordinates")
+	}
+	if _, cached := geoCache.Get("nowhere-test"); cached {
+		t.Error("cancelled lookup was cached")
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import "strings"
+
+// prettifyLocation turns an upstream slug like "los_angeles-usa" into a
+// display name like "Los Angeles, USA".
+func prettifyLocation(slug string) string {
+	parts := strings.Split(slug, "-")
+	for i, part := range parts {
+		parts[i] = prettifySlugPart(part, i > 0 && i == len(parts)-1)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// prettifySlugPart title-cases one "-"-separated part of a slug. Short
+// single-word countries such as usa or uk are upper-cased instead.
+func prettifySlugPart(part string, country bool) string {
+	words := strings.Fields(strings.ReplaceAll(part, "_", " "))
+	for j, w := range words {
+		if country && len(words) == 1 && len(w) <= 3 {
+			words[j] = strings.ToUpper(w)
+			continue
+		}
+		words[j] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// locationCountry returns the display name of the country part of a slug
+// ("los_angeles-usa" → "USA"), or "unknown" when the slug has none.
+func locationCountry(slug string) string {
+	i := strings.LastIndex(slug, "-")
+	if i < 0 || i == len(slug)-1 {
+		return "unknown"
+	}
+	return prettifySlugPart(slug[i+1:], true)
+}
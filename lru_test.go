@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestLRUCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newLRUCache[string, int](2)
+	c.Add("a", 1)
+	c.Add("b", 2)
+
+	// reading a makes b the least recently used
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf(`Get("a") = %d, %v; want 1, true`, v, ok)
+	}
+	c.Add("c", 3)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error(`"b" still cached after eviction`)
+	}
+	for key, want := range map[string]int{"a": 1, "c": 3} {
+		if v, ok := c.Get(key); !ok || v != want {
+			t.Errorf("Get(%q) = %d, %v; want %d, true", key, v, ok, want)
+		}
+	}
+	if s := c.Stats(); s != (CacheStats{Size: 2, Max: 2, Hits: 3, Misses: 1, Evictions: 1}) {
+		t.Errorf("Stats() = %+v", s)
+	}
+}
+
+func TestLRUCacheOverwrite(t *testing.T) {
+	c := newLRUCache[string, int](2)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Add("a", 10)
+
+	if v, _ := c.Get("a"); v != 10 {
+		t.Errorf(`Get("a") = %d after overwrite, want 10`, v)
+	}
+	if n := c.Len(); n != 2 {
+		t.Errorf("Len() = %d after overwrite, want 2", n)
+	}
+
+	// the overwrite counts as a use, so b goes first
+	c.Add("c", 3)
+	if _, ok := c.Get("b"); ok {
+		t.Error(`"b" still cached; overwriting "a" should have refreshed it`)
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error(`"a" evicted despite being overwritten last`)
+	}
+}
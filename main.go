@@ -1,45 +1,181 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"io"
 	"log"
+	"math/rand/v2"
+	"mime"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 )
 
 var (
-	tmpl       *template.Template
-	artistTmpl *template.Template
-	errorTmpl  *template.Template
+	tmpl        *template.Template
+	artistTmpl  *template.Template
+	errorTmpl   *template.Template
+	compareTmpl *template.Template
 )
 
 type PageData struct {
-	Artists   []Artist
-	Locations map[string][]string
-	Dates     map[string][]string
-	Relation  map[string][]string
-	Query     string 
-	MembersFilter string
+	Title         string
+	Artists       []Artist
+	Locations     map[int][]string
+	Dates         map[int][]string
+	Relation      map[int][]string
+	Query         string
+	Highlights    map[int][]NameSegment
+	Suggestion    string
+	SuggestionURL string
+	MembersFilter map[string]bool
+	Timeframe     string
+	View          string
+	Complete      bool
+	Location      string
+	Year          int
+	Match         string
+	Sort          string
+	TermMode      string
+	StartsWith    string
+	Letters       []LetterLink
+	FacetOptions  FacetOptions
+	Page          int
+	PageSize      int
+	TotalPages    int
+	Total         int
+	DateLayout    string
+	PrevURL       string
+	NextURL       string
 
+	// MinQueryLength is set when the query was too short to search
+	MinQueryLength int
+
+	// Stale is set when the data is older than cfg.StaleAfter
+	Stale bool
+
+	// FacetsURL is where the page fetches the location options from when
+	// they weren't rendered with it
+	FacetsURL string
+
+	// FilterQuery is the current search and filters, appended to the
+	// artist links so the detail page can link back to this view
+	FilterQuery template.URL
+}
+
+// LetterLink is one entry of the A–Z bar; an empty Letter clears the
+// filter.
+type LetterLink struct {
+	Letter string
+	URL    string
+	Active bool
 }
 
 type ArtistPageData struct {
-	Artist    Artist
-	Locations []string
-	Dates     []string
-	Relation  []string
+	Artist      Artist
+	Locations   []string
+	Dates       []string
+	Relation    []string
+	Breadcrumbs []Breadcrumb
+	DateLayout  string
+
+	// LocationGroups is the relation data by location, for the
+	// venue-centric view
+	LocationGroups []LocationGroup
+
+	// BackURL is the index view the visitor came from, filters included
+	BackURL string
+
+	// Stale is set when the data is older than cfg.StaleAfter
+	Stale bool
+
+	OpenGraph OpenGraph
+
+	// set by the handler so the template doesn't decide data presence
+	HasLocations bool
+	HasDates     bool
+	HasRelation  bool
+}
+
+// LocationGroup is every date an artist played one location.
+type LocationGroup struct {
+	Location string
+	Label    string
+	Dates    []string
+}
+
+// OpenGraph is the link preview metadata for an artist page.
+type OpenGraph struct {
+	Title       string
+	Description string
+	Image       string
+}
+
+// Breadcrumb is one step of the navigation trail; the current page has no URL.
+type Breadcrumb struct {
+	Label string
+	URL   string
 }
 
 type Artist struct {
-	ID         int      `json:"id"`
-	Name       string   `json:"name"`
-	Image      string   `json:"image"`
-	FirstAlbum string   `json:"firstAlbum"`
-	Members    []string `json:"members"`
+	ID           int      `json:"id"`
+	Name         string   `json:"name"`
+	Image        string   `json:"image"`
+	CreationDate int      `json:"creationDate"`
+	FirstAlbum   string   `json:"firstAlbum"`
+	Members      []string `json:"members"`
+
+	// Slug is not part of the upstream data; it is assigned on refresh.
+	Slug string `json:"slug"`
+}
+
+// placeholderImage is served in place of a missing or unusable artist
+// image.
+const placeholderImage = "/static/placeholder.svg"
+
+// AltText describes the artist's image for screen readers, e.g. "Photo
+// of the band Queen", or "Photo of Eminem" for a solo artist.
+func (a Artist) AltText() string {
+	name := strings.TrimSpace(a.Name)
+	switch {
+	case name == "":
+		return "Artist photo"
+	case len(a.Members) > 1:
+		return "Photo of the band " + name
+	default:
+		return "Photo of " + name
+	}
+}
+
+// ImageOrPlaceholder is the src to render for the artist's image. An
+// absolute http(s) image URL is linked directly, or through the /image
+// proxy when IMAGE_PROXY or PREFETCH_IMAGES is set; anything else, and
+// every image in offline mode, gets the bundled placeholder.
+func (a Artist) ImageOrPlaceholder() string {
+	if cfg.Offline {
+		return urlFor(placeholderImage)
+	}
+	image := strings.TrimSpace(a.Image)
+	u, err := url.Parse(image)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return urlFor(placeholderImage)
+	}
+	if !cfg.ImageProxy && !cfg.PrefetchImages {
+		return image
+	}
+	return urlFor("/image?id=" + strconv.Itoa(a.ID))
 }
 
 type LocationsAPI struct {
@@ -56,312 +192,861 @@ type DatesAPI struct {
 	} `json:"index"`
 }
 
+// RelationAPI keeps each entry raw so one malformed entry can be skipped
+// without failing the whole relation fetch.
 type RelationAPI struct {
-	Index []struct {
-		ID             int               `json:"id"`
-		DatesLocations map[string]string `json:"datesLocations"`
-	} `json:"index"`
+	Index []json.RawMessage `json:"index"`
+}
+
+var templateFuncs = template.FuncMap{
+	"join":       strings.Join,
+	"formatDate": formatDate,
+	"url":        urlFor,
+}
+
+// urlFor prefixes an internal path with cfg.BasePath. Every link and
+// redirect the app generates goes through here.
+func urlFor(path string) string {
+	return cfg.BasePath + path
 }
 
 const (
-	apiArtists   = "https://groupietrackers.herokuapp.com/api/artists"
-	apiLocations = "https://groupietrackers.herokuapp.com/api/locations"
-	apiDates     = "https://groupietrackers.herokuapp.com/api/dates"
-	apiRelation  = "https://groupietrackers.herokuapp.com/api/relation"
+	defaultPageSize = 20
+	maxPageSize     = 100
 )
 
-func main() {
+const defaultAPIBaseURL = "https://groupietrackers.herokuapp.com/api"
 
-	var err error
+// listenAddr is the address the server listens on.
+const listenAddr = ":8080"
 
-	// load index template
-	tmpl, err = template.New("index.html").
-		Funcs(template.FuncMap{"join": strings.Join}).
-		ParseFiles(filepath.Join("templates", "index.html"))
-	if err != nil {
-		log.Fatalf("Error loading index.html: %v", err)
-	}
+func main() {
 
-	// load artist template
-	artistTmpl, err = template.New("artist.html").
-		Funcs(template.FuncMap{"join": strings.Join}).
-		ParseFiles(filepath.Join("templates", "artist.html"))
-	if err != nil {
-		log.Fatalf("Error loading artist.html: %v", err)
+	cfg = loadConfig()
+	maintenance.Store(cfg.Maintenance)
+	if logFile := openLogFile(cfg.LogFile); logFile != nil {
+		defer logFile.Close()
 	}
+	geoCache = newLRUCache[string, geocodeResult](cfg.GeoCacheSize)
+	imageCache = newLRUCache[string, cachedImage](cfg.ImageCacheSize)
+	pageCache = newLRUCache[string, []byte](cfg.PageCacheSize)
 
-	// load error template
-	errorTmpl, err = template.ParseFiles(filepath.Join("templates", "error.html"))
-	if err != nil {
-		log.Fatalf("Error loading error.html: %v", err)
+	// pages can't render without templates; missing static files only
+	// leave them unstyled, so that is fatal in strict mode alone
+	checkDir("Template", cfg.TemplateDir, "TEMPLATE_DIR", true)
+	checkDir("Static", cfg.StaticDir, "STATIC_DIR", cfg.StrictStartup)
+
+	if err := loadSavedQueries(cfg.SavedQueriesFile); err != nil {
+		log.Fatalf("Failed to load saved queries: %v", err)
 	}
 
+	tmpl = loadTemplate("index.html")
+	artistTmpl = loadTemplate("artist.html")
+	errorTmpl = loadTemplate("error.html")
+	compareTmpl = loadTemplate("compare.html")
+
+	registerMIMETypes()
+
 	// routes
 	http.HandleFunc("/", handleIndex)
 	http.HandleFunc("/artist", handleArtist)
-	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+	http.HandleFunc("/artist/", handleArtistPath)
+	http.HandleFunc("/members/", handleMembers)
+	http.HandleFunc("/random", handleRandom)
+	http.HandleFunc("/compare", handleCompare)
+	http.HandleFunc("/export/relations.csv", handleRelationsCSV)
+	http.HandleFunc("/api/artist/geojson", handleArtistGeoJSON)
+	http.HandleFunc("/api/artist/bbox", handleArtistBBox)
+	http.HandleFunc("/api/artist/concerts", handleArtistConcerts)
+	http.HandleFunc("/api/artists/page", handleArtistsPage)
+	http.HandleFunc("/api/artists/batch", handleArtistsBatch)
+	http.HandleFunc("/api/search", handleSearch)
+	http.HandleFunc("/api/timeline", handleTimeline)
+	http.HandleFunc("/api/countries", handleCountries)
+	http.HandleFunc("/api/relation", handleRelation)
+	http.HandleFunc("/api/filters", handleFilters)
+	http.HandleFunc("/api/locations", handleLocations)
+	http.HandleFunc("/stats", handleStats)
+	http.HandleFunc("/image", handleImage)
+	http.HandleFunc("/version", handleVersion)
+	http.HandleFunc("/healthz", handleHealthz)
+	http.HandleFunc("/admin/maintenance", handleMaintenance)
+	http.HandleFunc("/admin/raw/", handleRawUpstream)
+	http.HandleFunc("/admin/config", handleConfig)
+	http.HandleFunc("/admin/saved", handleSavedQueries)
+	http.HandleFunc("/api/saved/", handleSavedQuery)
+	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(cfg.StaticDir))))
 
+	if cfg.Offline {
+		log.Println("Offline mode: serving bundled fixture data")
+	} else if probeUpstream() {
+		log.Println("Upstream API ready")
+	} else if cfg.StrictStartup {
+		log.Fatal("Upstream API not reachable, exiting (STRICT_STARTUP is set)")
+	} else {
+		log.Println("Warning: upstream API not fully reachable, starting anyway")
+	}
 	log.Println("Server running on http://localhost:8080")
 	log.Println("Press Ctrl+C to stop the server")
 
-	if err := http.ListenAndServe(":8080", nil); err != nil {
+	srv := &http.Server{
+		Addr:    listenAddr,
+		Handler: logRequests(stripBasePath(recoverPanics(rateLimit(limitConcurrency(maintenanceMode(canonicalPath(cors(answerOptions(requireAPIKey(withTimeout(withRequestData(http.DefaultServeMux)))))))))))),
+
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-serveErr:
 		log.Fatal(err)
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Shutdown error: %v", err)
+	}
+}
+
+// openLogFile points the logger at path, opened for appending, and
+// returns the file so main can close it on shutdown. With no path, or
+// if the file can't be opened, logging stays on stderr and nil is
+// returned.
+func openLogFile(path string) *os.File {
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		log.Printf("Warning: can't open log file %q, logging to stderr: %v", path, err)
+		return nil
+	}
+	log.SetOutput(f)
+	return f
+}
+
+// staticMIMETypes overrides the content types of static assets whose
+// defaults (taken partly from the host's mime.types) are missing or wrong.
+var staticMIMETypes = map[string]string{
+	".css":         "text/css; charset=utf-8",
+	".js":          "text/javascript; charset=utf-8",
+	".svg":         "image/svg+xml",
+	".webmanifest": "application/manifest+json",
+	".ico":         "image/x-icon",
+}
+
+func registerMIMETypes() {
+	for ext, typ := range staticMIMETypes {
+		if err := mime.AddExtensionType(ext, typ); err != nil {
+			log.Printf("Failed to register MIME type for %s: %v", ext, err)
+		}
 	}
 }
 
 func handleIndex(w http.ResponseWriter, r *http.Request) {
 
+	defer drainBody(r)
+
 	if r.URL.Path != "/" {
 		renderError(w, http.StatusNotFound, "Page Not Found")
 		return
 	}
 
-	if r.Method != http.MethodGet {
+	renderIndex(w, r)
+}
+
+// handleMembers serves /members/{range}, the index pre-filtered to one
+// member range (/members/3, /members/2-4, /members/5-), so "three-piece
+// bands" has a bookmarkable URL.
+func handleMembers(w http.ResponseWriter, r *http.Request) {
+
+	defer drainBody(r)
+
+	mr, err := parseMemberRange(strings.TrimPrefix(r.URL.Path, "/members/"))
+	if err != nil {
+		renderError(w, http.StatusNotFound, "Page Not Found")
+		return
+	}
+
+	r2 := r.Clone(r.Context())
+	q := r2.URL.Query()
+	q.Set("members", mr.String())
+	r2.URL.RawQuery = q.Encode()
+
+	renderIndex(w, r2)
+}
+
+// renderIndex renders the artist list using the search, filter and
+// pagination parameters in r's query string.
+func renderIndex(w http.ResponseWriter, r *http.Request) {
+
+	if !allowGetHead(w, r) {
 		renderError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
 		return
 	}
 
-	artists, err := fetchArtists()
+	params, err := parseIndexParams(r.URL.Query())
 	if err != nil {
-		renderError(w, http.StatusInternalServerError, "Failed to fetch artists")
+		renderError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	query := strings.ToLower(r.URL.Query().Get("q"))
-	var filtered []Artist
-	if len(query) >= 30 {
-		renderError(w, http.StatusBadRequest, "Limit reached") 
+	// the plain list needs only the artists; the other three endpoints
+	// are fetched when a search or filter uses them
+	load := loadArtistData
+	if params.needsFullData() {
+		load = loadAllData
 	}
-	if query != "" {
-		for _, a := range artists {
-			if strings.Contains(strings.ToLower(a.Name), query) {
-				filtered = append(filtered, a)
-			}
-		}
+	data, err := load(r.Context())
+	if err != nil {
+		renderError(w, fetchErrorStatus(err), "Failed to fetch artists")
+		return
+	}
+
+	if err := params.checkKnownValues(data); err != nil {
+		renderError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	prefetchImages(data.Artists)
+	if params.Sort == "concerts" && data.DatesLocations == nil {
+		renderError(w, http.StatusServiceUnavailable, "Concert data unavailable")
+		return
+	}
+	query := params.Query
+
+	// explicit view and page size choices are remembered; otherwise fall
+	// back to the remembered ones
+	if params.View != "" {
+		setPreference(w, "view", params.View)
+	} else if c, err := r.Cookie("view"); err == nil && c.Value == "table" {
+		params.View = "table"
 	} else {
-		filtered = artists
-	}
-
-	membersFilter := r.URL.Query().Get("members")
-
-	if membersFilter != "" {
-		var temp []Artist
-
-		for _, a := range filtered {
-			count := len(a.Members)
-
-			switch membersFilter {
-			case "1":
-				if count == 1 {
-					temp = append(temp, a)
-				}
-			case "2":
-				if count == 2 {
-					temp = append(temp, a)
-				}
-			case "3":
-				if count == 3 {
-					temp = append(temp, a)
-				}
-			case "4":
-				if count == 4 {
-					temp = append(temp, a)
-				}
-			case "5":
-				if count >= 5 {
-					temp = append(temp, a)
-				}
-			}
+		params.View = "cards"
+	}
+
+	if r.URL.Query().Get("pageSize") != "" {
+		setPreference(w, "pageSize", strconv.Itoa(params.PageSize))
+	} else if c, err := r.Cookie("pageSize"); err == nil {
+		if n, err := parsePageSize(c.Value); err == nil {
+			params.PageSize = n
 		}
+	}
+
+	now := time.Now()
+	dateLayout := dateLayoutFor(r)
+	cacheKey := indexCacheKey(data, r.URL.RawQuery, params, dateLayout, now)
+	if page, ok := pageCache.Get(cacheKey); ok {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+		return
+	}
+
+	filtered := filterArtists(data, params, now)
+	promptMore := params.QueryTooShort && cfg.ShortQueryPrompt
+	if promptMore {
+		filtered = nil
+	}
 
-		filtered = temp
+	pageSize := params.PageSize
+	page := params.Page
+
+	total := len(filtered)
+	totalPages := (total + pageSize - 1) / pageSize
+	if totalPages < 1 {
+		totalPages = 1
 	}
+	page = min(max(page, 1), totalPages)
 
-	locations, _ := fetchLocations()
-	dates, _ := fetchDates()
-	relation, _ := fetchRelation()
+	start := (page - 1) * pageSize
+	end := min(start+pageSize, total)
 
 	pageData := PageData{
-		Artists:       filtered,
-		Locations:     locations,
-		Dates:         dates,
-		Relation:      relation,
+		Title:         indexTitle(params, total),
+		Artists:       filtered[start:end],
+		Locations:     data.Locations,
+		Dates:         data.Dates,
+		Relation:      data.Relation,
 		Query:         query,
-		MembersFilter: membersFilter,
+		Highlights:    make(map[int][]NameSegment),
+		MembersFilter: make(map[string]bool),
+		Timeframe:     params.Timeframe,
+		View:          params.View,
+		Complete:      params.Complete,
+		Location:      params.Location,
+		Year:          params.Year,
+		Match:         params.Match,
+		Sort:          params.Sort,
+		TermMode:      params.TermMode,
+		StartsWith:    params.StartsWith,
+		FacetOptions:  buildFacetOptions(data),
+		Page:          page,
+		PageSize:      pageSize,
+		TotalPages:    totalPages,
+		Total:         total,
+		DateLayout:    dateLayout,
+		Stale:         data.Stale(),
+	}
+	for _, mr := range params.Members {
+		pageData.MembersFilter[mr.String()] = true
+	}
+	if fq := indexQuery(r.URL.Query()); len(fq) > 0 {
+		pageData.FilterQuery = template.URL("?" + fq.Encode())
+	}
+	if promptMore {
+		pageData.MinQueryLength = cfg.MinQueryLength
+	}
+	if data.Locations == nil {
+		// artists-only data has no location options; the page fetches
+		// them once it has loaded
+		pageData.FacetsURL = urlFor("/api/filters")
+	}
+	if query != "" && !params.QueryTooShort {
+		for _, a := range pageData.Artists {
+			pageData.Highlights[a.ID] = highlightSegments(a.Name, params.Terms)
+		}
+	}
+
+	if query != "" && !params.QueryTooShort && total == 0 {
+		if name := suggestArtistName(data.Artists, query); name != "" {
+			pageData.Suggestion = name
+			pageData.SuggestionURL = searchURL(r, name)
+		}
+	}
+
+	pageData.Letters = append(pageData.Letters, LetterLink{URL: letterURL(r, ""), Active: params.StartsWith == ""})
+	for _, l := range pageData.FacetOptions.Letters {
+		pageData.Letters = append(pageData.Letters, LetterLink{Letter: l, URL: letterURL(r, l), Active: params.StartsWith == l})
 	}
 
-	if err := tmpl.Execute(w, pageData); err != nil {
+	if page > 1 {
+		pageData.PrevURL = pageURL(r, page-1)
+	}
+	if page < totalPages {
+		pageData.NextURL = pageURL(r, page+1)
+	}
+
+	if tmpl == nil {
+		renderError(w, http.StatusInternalServerError, "Page temporarily unavailable")
+		return
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, pageData); err != nil {
 		renderError(w, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+	pageCache.Add(cacheKey, buf.Bytes())
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
+
+// maxDrainBytes bounds how much of an unexpected request body is read
+// before giving up on reusing the connection.
+const maxDrainBytes = 1 << 20
+
+// drainBody discards and closes any body sent with a GET request so the
+// connection can be reused, whichever path the handler returns on.
+func drainBody(r *http.Request) {
+	io.Copy(io.Discard, io.LimitReader(r.Body, maxDrainBytes))
+	r.Body.Close()
+}
+
+// checkDir logs when dir is missing or not a directory, exiting if fatal.
+func checkDir(what, dir, env string, fatal bool) {
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		return
+	}
+	if fatal {
+		log.Fatalf("%s directory %q not found (set %s to override)", what, dir, env)
 	}
+	log.Printf("Warning: %s directory %q not found (set %s to override)", strings.ToLower(what), dir, env)
 }
 
+// loadTemplate parses a template from cfg.TemplateDir. A parse failure
+// is fatal unless cfg.TolerateTemplateErrors is set, in which case it is
+// logged and nil is returned so the other pages keep working.
+func loadTemplate(name string) *template.Template {
+	t, err := template.New(name).
+		Funcs(templateFuncs).
+		ParseFiles(filepath.Join(cfg.TemplateDir, name))
+	if err != nil {
+		if !cfg.TolerateTemplateErrors {
+			log.Fatalf("Error loading %s: %v", name, err)
+		}
+		log.Printf("Error loading %s, serving a fallback page instead: %v", name, err)
+		return nil
+	}
+	return t
+}
+
+// allowGetHead reports whether r is a GET or HEAD request. HEAD is served
+// like GET; net/http drops the body. Otherwise it sets the Allow header
+// for the 405 response.
+func allowGetHead(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return true
+	}
+	w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+	return false
+}
+
+// setPreference stores a display preference in a long-lived cookie.
+func setPreference(w http.ResponseWriter, name, value string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     urlFor("/"),
+		MaxAge:   365 * 24 * 60 * 60,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// indexTitle is the index page's <title>, naming the search and the
+// result count when a search or filter is active, e.g.
+// "Search: queen (3 results) - Groupie Tracker".
+func indexTitle(p IndexParams, total int) string {
+	results := fmt.Sprintf("%d results", total)
+	if total == 1 {
+		results = "1 result"
+	}
+
+	filtered := len(p.Members) > 0 || p.Location != "" || p.Year != 0 ||
+		p.Timeframe != "" || p.Complete || p.StartsWith != ""
+	switch {
+	case p.Query != "" && !p.QueryTooShort:
+		return fmt.Sprintf("Search: %s (%s) - Groupie Tracker", p.Query, results)
+	case filtered:
+		return fmt.Sprintf("Filtered artists (%s) - Groupie Tracker", results)
+	default:
+		return "Groupie Tracker - Artists"
+	}
+}
+
+// pageURL returns the current index URL with its page parameter replaced,
+// so pagination links keep the active search and filters.
+func pageURL(r *http.Request, page int) string {
+	q := r.URL.Query()
+	q.Set("page", strconv.Itoa(page))
+	return urlFor("/?" + q.Encode())
+}
+
+// letterURL is the current index URL with the startsWith filter set to
+// letter, or removed when letter is empty.
+func letterURL(r *http.Request, letter string) string {
+	q := r.URL.Query()
+	if letter == "" {
+		q.Del("startsWith")
+	} else {
+		q.Set("startsWith", letter)
+	}
+	q.Del("page")
+	if len(q) == 0 {
+		return urlFor("/")
+	}
+	return urlFor("/?" + q.Encode())
+}
+
+// parsePageSize reads the pageSize parameter, defaulting when empty and
+// clamping to [1, maxPageSize]. Non-numeric values are rejected.
+func parsePageSize(s string) (int, error) {
+	if s == "" {
+		return defaultPageSize, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	return min(max(n, 1), maxPageSize), nil
+}
 
 func handleArtist(w http.ResponseWriter, r *http.Request) {
 
+	defer drainBody(r)
+
 	if r.URL.Path != "/artist" {
 		renderError(w, http.StatusNotFound, "Page Not Found")
 		return
 	}
 
-	if r.Method != http.MethodGet {
+	if !allowGetHead(w, r) {
 		renderError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
 		return
 	}
 
-	idStr := r.URL.Query().Get("id")
-	if idStr == "" {
+	// the id may be numeric or a slug
+	ref, err := singleParam(r.URL.Query(), "id")
+	if err != nil {
+		renderError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if ref == "" {
 		renderError(w, http.StatusBadRequest, "Missing artist id")
 		return
 	}
 
-	id, err := strconv.Atoi(idStr)
+	data, err := loadAllData(r.Context())
 	if err != nil {
-		renderError(w, http.StatusBadRequest, "Invalid artist id")
+		renderError(w, fetchErrorStatus(err), "Failed to fetch artists")
 		return
 	}
 
-	artists, err := fetchArtists()
-	if err != nil {
-		renderError(w, http.StatusInternalServerError, "Failed to fetch artists")
+	artist, found := data.resolveArtist(ref)
+	if !found {
+		renderArtistNotFound(w, data.Artists)
 		return
 	}
+	id := artist.ID
 
-	var artist Artist
-	found := false
-	for _, a := range artists {
-		if a.ID == id {
-			artist = a
-			found = true
-			break
-		}
-	}
+	recordArtistView(id)
 
-	if !found {
-		renderError(w, http.StatusNotFound, "Artist not found")
-		return
+	pageData, ok := data.ArtistPages[id]
+	if !ok {
+		pageData = buildArtistPage(data, artist)
 	}
+	pageData.DateLayout = dateLayoutFor(r)
+	pageData.Stale = data.Stale()
 
-	locationsMap, _ := fetchLocations()
-	datesMap, _ := fetchDates()
-	relationMap, _ := fetchRelation()
+	// link back to the index view the visitor came from
+	pageData.BackURL = urlFor("/")
+	if fq := indexQuery(r.URL.Query()); len(fq) > 0 {
+		pageData.BackURL = urlFor("/?" + fq.Encode())
+	}
+	pageData.Breadcrumbs = []Breadcrumb{
+		{Label: "Home", URL: urlFor("/")},
+		{Label: "Artists", URL: pageData.BackURL},
+		{Label: artist.Name},
+	}
 
-	key := fmt.Sprintf("%d", id)
+	if artistTmpl == nil {
+		renderError(w, http.StatusInternalServerError, "Page temporarily unavailable")
+		return
+	}
+	if err := artistTmpl.Execute(w, pageData); err != nil {
+		renderError(w, http.StatusInternalServerError, "Failed to render artist page")
+	}
+}
 
-	data := ArtistPageData{
+// buildArtistPage assembles the request-independent parts of an artist's
+// detail page; the handler fills in DateLayout and the links back.
+func buildArtistPage(data *Dataset, artist Artist) ArtistPageData {
+	id := artist.ID
+	pageData := ArtistPageData{
 		Artist:    artist,
-		Locations: locationsMap[key],
-		Dates:     datesMap[key],
-		Relation:  relationMap[key],
+		Locations: data.Locations[id],
+		Dates:     data.Dates[id],
+		Relation:  data.Relation[id],
 	}
+	pageData.LocationGroups = groupByLocation(data.DatesLocations[id])
+	pageData.OpenGraph = artistOpenGraph(artist)
+	pageData.HasLocations = len(pageData.Locations) > 0
+	pageData.HasDates = len(pageData.Dates) > 0
+	pageData.HasRelation = len(pageData.Relation) > 0
+	return pageData
+}
 
-	if err := artistTmpl.Execute(w, data); err != nil {
-		renderError(w, http.StatusInternalServerError, "Failed to render artist page")
+// artistOpenGraph summarises an artist for link previews, e.g.
+// "Queen: 7 members, first album 14 Dec 1973."
+func artistOpenGraph(a Artist) OpenGraph {
+	members := fmt.Sprintf("%d members", len(a.Members))
+	if len(a.Members) == 1 {
+		members = "solo artist"
+	}
+
+	return OpenGraph{
+		Title:       a.Name + " - Groupie Tracker",
+		Description: fmt.Sprintf("%s: %s, first album %s.", a.Name, members, formatDate(defaultDateLayout, a.FirstAlbum)),
+		Image:       a.Image,
 	}
 }
 
-func fetchArtists() ([]Artist, error) {
-	resp, err := http.Get(apiArtists)
+// handleRandom sends the visitor to a uniformly random artist page, or
+// back to the index if there are no artists.
+func handleRandom(w http.ResponseWriter, r *http.Request) {
+
+	if !allowGetHead(w, r) {
+		renderError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	data, err := loadArtistData(r.Context())
 	if err != nil {
-		return nil, err
+		renderError(w, fetchErrorStatus(err), "Failed to fetch artists")
+		return
+	}
+
+	if len(data.Artists) == 0 {
+		http.Redirect(w, r, urlFor("/"), http.StatusFound)
+		return
+	}
+
+	// pick by position, not ID, so gaps in the IDs don't skew the odds
+	artist := data.Artists[rand.IntN(len(data.Artists))]
+	http.Redirect(w, r, urlFor("/artist/"+artist.Slug), http.StatusFound)
+}
+
+// handleArtistPath serves /artist/{slug} pretty URLs and redirects legacy
+// /artist/{id} links to /artist?id={id}, keeping any other query
+// parameters.
+func handleArtistPath(w http.ResponseWriter, r *http.Request) {
+
+	ref := strings.TrimPrefix(r.URL.Path, "/artist/")
+	if ref == "" || strings.Contains(ref, "/") {
+		renderError(w, http.StatusNotFound, "Page Not Found")
+		return
+	}
+
+	q := r.URL.Query()
+	q.Set("id", ref)
+
+	if id, err := strconv.Atoi(ref); err == nil {
+		if id < 1 {
+			renderError(w, http.StatusNotFound, "Page Not Found")
+			return
+		}
+		http.Redirect(w, r, urlFor("/artist?"+q.Encode()), http.StatusMovedPermanently)
+		return
 	}
-	defer resp.Body.Close()
 
+	r2 := r.Clone(r.Context())
+	r2.URL.Path = "/artist"
+	r2.URL.RawQuery = q.Encode()
+	handleArtist(w, r2)
+}
+
+func fetchArtists(ctx context.Context) ([]Artist, error) {
 	var artists []Artist
-	if err := json.NewDecoder(resp.Body).Decode(&artists); err != nil {
+	if err := decodeUpstream(ctx, "artists", &artists); err != nil {
+		return nil, err
+	}
+	if err := validateArtists(artists); err != nil {
 		return nil, err
 	}
-	return artists, nil
+	return dedupeArtists(artists), nil
 }
 
-func fetchLocations() (map[string][]string, error) {
-	resp, err := http.Get(apiLocations)
-	if err != nil {
-		return nil, err
+// dedupeArtists drops repeated artist IDs, keeping the first occurrence,
+// so ArtistsByID and the slugs stay unambiguous if the upstream glitches.
+func dedupeArtists(artists []Artist) []Artist {
+	seen := make(map[int]bool, len(artists))
+	kept := artists[:0]
+	for _, a := range artists {
+		if seen[a.ID] {
+			log.Printf("Dropping duplicate artist id %d (%q)", a.ID, a.Name)
+			continue
+		}
+		seen[a.ID] = true
+		kept = append(kept, a)
 	}
-	defer resp.Body.Close()
+	return kept
+}
 
+func fetchLocations(ctx context.Context) (map[int][]string, error) {
 	var data LocationsAPI
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+	if err := decodeUpstream(ctx, "locations", &data); err != nil {
+		return nil, err
+	}
+	if err := validateLocations(data); err != nil {
 		return nil, err
 	}
 
-	result := make(map[string][]string)
+	result := make(map[int][]string)
 	for _, entry := range data.Index {
-		result[fmt.Sprintf("%d", entry.ID)] = entry.Locations
+		result[entry.ID] = entry.Locations
 	}
 	return result, nil
 }
 
-func fetchDates() (map[string][]string, error) {
-	resp, err := http.Get(apiDates)
-	if err != nil {
+func fetchDates(ctx context.Context) (map[int][]string, error) {
+	var data DatesAPI
+	if err := decodeUpstream(ctx, "dates", &data); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	var data DatesAPI
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+	if err := validateDates(data); err != nil {
 		return nil, err
 	}
 
-	result := make(map[string][]string)
+	result := make(map[int][]string)
 	for _, entry := range data.Index {
-		result[fmt.Sprintf("%d", entry.ID)] = entry.Dates
+		result[entry.ID] = entry.Dates
 	}
 	return result, nil
 }
 
-func fetchRelation() (map[string][]string, error) {
-	resp, err := http.Get(apiRelation)
-	if err != nil {
+func fetchRelation(ctx context.Context) (map[int]map[string][]string, error) {
+	var data RelationAPI
+	if err := decodeUpstream(ctx, "relation", &data); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	var data RelationAPI
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return nil, err
+	result := make(map[int]map[string][]string)
+	for i, raw := range data.Index {
+		id, datesLocations, err := decodeRelationEntry(raw)
+		if err != nil {
+			log.Printf("Skipping relation entry %d: %v", i, err)
+			continue
+		}
+		result[id] = datesLocations
+	}
+	return result, nil
+}
+
+// decodeRelationEntry decodes one relation entry defensively. Each
+// location normally maps to a list of dates; a single date string is
+// accepted too, and locations with null or other values are skipped.
+func decodeRelationEntry(raw json.RawMessage) (int, map[string][]string, error) {
+	var entry struct {
+		ID             int                        `json:"id"`
+		DatesLocations map[string]json.RawMessage `json:"datesLocations"`
+	}
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return 0, nil, err
+	}
+	if entry.ID <= 0 {
+		return 0, nil, fmt.Errorf("invalid id %d", entry.ID)
 	}
 
-	result := make(map[string][]string)
-	for _, entry := range data.Index {
-		arr := []string{}
-		for date, location := range entry.DatesLocations {
+	datesLocations := make(map[string][]string, len(entry.DatesLocations))
+	for location, value := range entry.DatesLocations {
+		var dates []string
+		if err := json.Unmarshal(value, &dates); err == nil && dates != nil {
+			datesLocations[location] = dates
+			continue
+		}
+		var date string
+		if err := json.Unmarshal(value, &date); err == nil && date != "" {
+			datesLocations[location] = []string{date}
+			continue
+		}
+		log.Printf("Skipping relation location %q for artist %d: unexpected value %s", location, entry.ID, value)
+	}
+	return entry.ID, datesLocations, nil
+}
+
+// formatRelation flattens an artist's location → dates map into
+// "date → location" lines for display.
+func formatRelation(datesLocations map[string][]string) []string {
+	arr := []string{}
+	for location, dates := range datesLocations {
+		for _, date := range dates {
 			arr = append(arr, fmt.Sprintf("%s → %s", date, location))
 		}
-		result[fmt.Sprintf("%d", entry.ID)] = arr
 	}
-	return result, nil
+	return arr
 }
 
+// groupByLocation lists an artist's relation data one location at a
+// time, sorted by label, with each location's dates in chronological
+// order. Dates that don't parse go last.
+func groupByLocation(datesLocations map[string][]string) []LocationGroup {
+	groups := make([]LocationGroup, 0, len(datesLocations))
+	for location, dates := range datesLocations {
+		sorted := slices.Clone(dates)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			ti, erri := parseConcertDate(sorted[i])
+			tj, errj := parseConcertDate(sorted[j])
+			if erri != nil || errj != nil {
+				return erri == nil && errj != nil
+			}
+			return ti.Before(tj)
+		})
+		groups = append(groups, LocationGroup{Location: location, Label: prettifyLocation(location), Dates: sorted})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Label != groups[j].Label {
+			return groups[i].Label < groups[j].Label
+		}
+		return groups[i].Location < groups[j].Location
+	})
+	return groups
+}
 
 type ErrorData struct {
 	Code    int
 	Title   string
 	Message string
+
+	// Suggestions lists artists to offer when the requested one is missing.
+	Suggestions []Artist
 }
 
-func renderError(w http.ResponseWriter, code int, msg string) {
+// maxNotFoundSuggestions caps how many artists the not-found page lists.
+const maxNotFoundSuggestions = 5
 
-	w.WriteHeader(code)
+func renderError(w http.ResponseWriter, code int, msg string) {
 
-	data := ErrorData{
+	writeErrorPage(w, ErrorData{
 		Code:    code,
+		Title:   errorTitle(code),
 		Message: msg,
+	})
+}
+
+// renderArtistNotFound renders the 404 page for an unknown artist id,
+// listing a few artists so the user has somewhere to go next.
+func renderArtistNotFound(w http.ResponseWriter, artists []Artist) {
+
+	suggestions := artists
+	if len(suggestions) > maxNotFoundSuggestions {
+		suggestions = suggestions[:maxNotFoundSuggestions]
 	}
 
+	writeErrorPage(w, ErrorData{
+		Code:        http.StatusNotFound,
+		Title:       errorTitle(http.StatusNotFound),
+		Message:     "Artist not found",
+		Suggestions: suggestions,
+	})
+}
+
+func errorTitle(code int) string {
+
 	switch code {
 	case http.StatusBadRequest:
-		data.Title = "400 — Bad Request"
+		return "400 — Bad Request"
 	case http.StatusNotFound:
-		data.Title = "404 — Not Found"
+		return "404 — Not Found"
+	case http.StatusTooManyRequests:
+		return "429 — Too Many Requests"
 	case http.StatusInternalServerError:
-		data.Title = "500 — Internal Server Error"
+		return "500 — Internal Server Error"
+	case http.StatusServiceUnavailable:
+		return "503 — Service Unavailable"
+	case http.StatusGatewayTimeout:
+		return "504 — Gateway Timeout"
 	default:
-		data.Title = fmt.Sprintf("Error %d", code)
+		return fmt.Sprintf("Error %d", code)
 	}
+}
+
+func writeErrorPage(w http.ResponseWriter, data ErrorData) {
 
-	if err := errorTmpl.Execute(w, data); err != nil {
-		http.Error(w, msg, http.StatusInternalServerError)
+	if errorTmpl == nil {
+		http.Error(w, data.Message, data.Code)
+		return
 	}
+
+	// render first so a template failure can still choose the status
+	var buf bytes.Buffer
+	if err := errorTmpl.Execute(&buf, data); err != nil {
+		log.Printf("Failed to render error page: %v", err)
+		http.Error(w, data.Message, data.Code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(data.Code)
+	buf.WriteTo(w)
 }
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	cfg = loadConfig()
+	// tests run against the bundled fixtures unless they start a mock
+	// upstream of their own
+	cfg.Offline = true
+	geoCache = newLRUCache[string, geocodeResult](cfg.GeoCacheSize)
+	imageCache = newLRUCache[string, cachedImage](cfg.ImageCacheSize)
+	pageCache = newLRUCache[string, []byte](cfg.PageCacheSize)
+
+	os.Exit(m.Run())
+}
+
+// loadFixtures builds a dataset from the bundled fixtures.
+func loadFixtures(tb testing.TB) *Dataset {
+	tb.Helper()
+	prev := cfg.Offline
+	cfg.Offline = true
+	defer func() { cfg.Offline = prev }()
+
+	data, err := fetchAll(context.Background())
+	if err != nil {
+		tb.Fatalf("loading fixtures: %v", err)
+	}
+	return data
+}
+
+// mockUpstream serves the bundled fixtures over HTTP like the real API,
+// counting the requests made for each endpoint.
+type mockUpstream struct {
+	*httptest.Server
+
+	// delay holds every response back, so concurrent callers overlap
+	delay time.Duration
+
+	mu   sync.Mutex
+	hits map[string]int
+}
+
+// newMockUpstream starts a mock upstream and points cfg.APIBaseURL at it
+// with cold caches, restoring both when the test ends.
+func newMockUpstream(tb testing.TB) *mockUpstream {
+	tb.Helper()
+	m := &mockUpstream{hits: make(map[string]int)}
+	m.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := strings.TrimPrefix(r.URL.Path, "/")
+		m.mu.Lock()
+		m.hits[endpoint]++
+		m.mu.Unlock()
+
+		time.Sleep(m.delay)
+		b, err := fixtureFS.ReadFile(path.Join("fixtures", endpoint+".json"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	}))
+
+	prevURL, prevOffline := cfg.APIBaseURL, cfg.Offline
+	cfg.APIBaseURL, cfg.Offline = m.URL, false
+	resetCaches()
+	tb.Cleanup(func() {
+		m.Close()
+		cfg.APIBaseURL, cfg.Offline = prevURL, prevOffline
+		resetCaches()
+	})
+	return m
+}
+
+// Hits is how many requests endpoint has received.
+func (m *mockUpstream) Hits(endpoint string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.hits[endpoint]
+}
+
+// resetCaches forgets every cached dataset and rendered page, so the next
+// load goes to the upstream.
+func resetCaches() {
+	cache.Lock()
+	cache.data, cache.fetched = nil, time.Time{}
+	cache.Unlock()
+	artistCache.Lock()
+	artistCache.data = nil
+	artistCache.Unlock()
+	lastFetched.Store(0)
+	pageCache = newLRUCache[string, []byte](cfg.PageCacheSize)
+}
+
+// useErrorTemplate parses templates/error.html into errorTmpl for the
+// duration of the test.
+func useErrorTemplate(t testing.TB) {
+	t.Helper()
+	prev := errorTmpl
+	errorTmpl = loadTemplate("error.html")
+	t.Cleanup(func() { errorTmpl = prev })
+}
+
+// useTemplates parses the page templates for the duration of the test.
+func useTemplates(tb testing.TB) {
+	tb.Helper()
+	prev := [...]*template.Template{tmpl, artistTmpl, errorTmpl, compareTmpl}
+	tmpl = loadTemplate("index.html")
+	artistTmpl = loadTemplate("artist.html")
+	errorTmpl = loadTemplate("error.html")
+	compareTmpl = loadTemplate("compare.html")
+	tb.Cleanup(func() {
+		tmpl, artistTmpl, errorTmpl, compareTmpl = prev[0], prev[1], prev[2], prev[3]
+	})
+}
+
+var titlePattern = regexp.MustCompile(`<title>(.*?)</title>`)
+
+func TestRenderErrorStatusAndTitle(t *testing.T) {
+	useErrorTemplate(t)
+
+	tests := []struct {
+		code  int
+		title string
+	}{
+		{http.StatusBadRequest, "400 — Bad Request"},
+		{http.StatusNotFound, "404 — Not Found"},
+		{http.StatusInternalServerError, "500 — Internal Server Error"},
+		{http.StatusTeapot, "Error 418"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		renderError(rec, tt.code, "something went wrong")
+
+		if rec.Code != tt.code {
+			t.Errorf("renderError(%d): status %d", tt.code, rec.Code)
+		}
+		m := titlePattern.FindStringSubmatch(rec.Body.String())
+		if m == nil {
+			t.Errorf("renderError(%d): no <title> in page", tt.code)
+			continue
+		}
+		if m[1] != tt.title {
+			t.Errorf("renderError(%d): title %q, want %q", tt.code, m[1], tt.title)
+		}
+	}
+}
+
+func BenchmarkHandleIndex(b *testing.B) {
+	newMockUpstream(b)
+	useTemplates(b)
+	handler := withRequestData(http.HandlerFunc(handleIndex))
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if rec.Code != http.StatusOK {
+				b.Errorf("GET /: status %d", rec.Code)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkHandleArtist(b *testing.B) {
+	newMockUpstream(b)
+	useTemplates(b)
+	handler := withRequestData(http.HandlerFunc(handleArtist))
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/artist?id=queen", nil))
+			if rec.Code != http.StatusOK {
+				b.Errorf("GET /artist: status %d", rec.Code)
+				return
+			}
+		}
+	})
+}
+
+func TestArtistPageSectionsWithDatesOnly(t *testing.T) {
+	useTemplates(t)
+
+	artist := Artist{ID: 1, Name: "Queen", Members: []string{"Freddie Mercury"}}
+	data := &Dataset{
+		Artists: []Artist{artist},
+		Dates:   map[int][]string{1: {"*23-08-2019", "22-08-2019"}},
+	}
+	indexArtists(data)
+
+	page := buildArtistPage(data, artist)
+	if page.HasLocations || !page.HasDates || page.HasRelation {
+		t.Errorf("HasLocations, HasDates, HasRelation = %v, %v, %v; want false, true, false",
+			page.HasLocations, page.HasDates, page.HasRelation)
+	}
+
+	page.DateLayout = defaultDateLayout
+	var buf strings.Builder
+	if err := artistTmpl.Execute(&buf, page); err != nil {
+		t.Fatal(err)
+	}
+	body := buf.String()
+	for heading, want := range map[string]bool{
+		"<h3>Locations</h3>":         false,
+		"<h3>Concert Dates</h3>":     true,
+		"<h3>Dates → Locations</h3>": false,
+	} {
+		if strings.Contains(body, heading) != want {
+			t.Errorf("page has %s: %v, want %v", heading, !want, want)
+		}
+	}
+}
+
+func TestImageOrPlaceholder(t *testing.T) {
+	prev := cfg
+	t.Cleanup(func() { cfg = prev })
+
+	artist := Artist{ID: 3, Image: "https://example.com/3.jpeg"}
+	tests := []struct {
+		offline, proxy, prefetch bool
+		want                     string
+	}{
+		{want: "https://example.com/3.jpeg"},
+		{proxy: true, want: "/image?id=3"},
+		{prefetch: true, want: "/image?id=3"},
+		{offline: true, proxy: true, want: placeholderImage},
+	}
+	for _, tt := range tests {
+		cfg.Offline, cfg.ImageProxy, cfg.PrefetchImages = tt.offline, tt.proxy, tt.prefetch
+		if got := artist.ImageOrPlaceholder(); got != tt.want {
+			t.Errorf("offline=%v proxy=%v prefetch=%v: %q, want %q", tt.offline, tt.proxy, tt.prefetch, got, tt.want)
+		}
+	}
+
+	cfg.Offline = false
+	if got := (Artist{Image: "not a url"}).ImageOrPlaceholder(); got != placeholderImage {
+		t.Errorf("unusable image URL: %q, want the placeholder", got)
+	}
+}
+
+func TestIndexFetchesOnlyWhatItNeeds(t *testing.T) {
+	useTemplates(t)
+
+	tests := []struct {
+		query string
+		full  bool
+	}{
+		{"", false},
+		{"members=4&year=1970&startsWith=q", false},
+		{"q=queen", true},
+		{"timeframe=past", true},
+		{"sort=concerts", true},
+	}
+	for _, tt := range tests {
+		upstream := newMockUpstream(t)
+		rec := httptest.NewRecorder()
+		withRequestData(http.HandlerFunc(handleIndex)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /?%s: status %d", tt.query, rec.Code)
+		}
+
+		if n := upstream.Hits("artists"); n != 1 {
+			t.Errorf("GET /?%s: artists fetched %d times, want 1", tt.query, n)
+		}
+		want := 0
+		if tt.full {
+			want = 1
+		}
+		for _, endpoint := range []string{"locations", "dates", "relation"} {
+			if n := upstream.Hits(endpoint); n != want {
+				t.Errorf("GET /?%s: %s fetched %d times, want %d", tt.query, endpoint, n, want)
+			}
+		}
+
+		// without the location data, the page loads the options itself
+		if got := strings.Contains(rec.Body.String(), `data-options="/api/filters"`); got == tt.full {
+			t.Errorf("GET /?%s: fetches location options: %v, want %v", tt.query, got, !tt.full)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"path"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stripBasePath serves the app under cfg.BasePath: the prefix is removed
+// before routing so the handlers and middleware below see root-relative
+// paths, and anything outside it is a 404. The bare prefix redirects to
+// its trailing-slash form, the index.
+func stripBasePath(next http.Handler) http.Handler {
+	if cfg.BasePath == "" {
+		return next
+	}
+	strip := http.StripPrefix(cfg.BasePath, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == cfg.BasePath {
+			target := cfg.BasePath + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, cfg.BasePath+"/") {
+			http.NotFound(w, r)
+			return
+		}
+		strip.ServeHTTP(w, r)
+	})
+}
+
+// canonicalPath redirects paths with trailing slashes (e.g. /artist/) to
+// their canonical form (/artist), keeping the query string intact.
+func canonicalPath(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := r.URL.Path
+
+		// the root and the static file tree keep their slashes
+		if p == "/" || strings.HasPrefix(p, "/static/") || !strings.HasSuffix(p, "/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// this runs before ServeMux cleans the path, so "//evil.com/" is
+		// cleaned here; otherwise it would redirect off-site as
+		// "//evil.com". Browsers read "/\" like "//", so those are left
+		// for the mux to answer.
+		target := path.Clean(p)
+		if strings.HasPrefix(target, "/\\") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+
+		http.Redirect(w, r, urlFor(target), http.StatusMovedPermanently)
+	})
+}
+
+// requireAPIKey guards the /api/ routes when API_KEY is set: requests
+// must carry the key in an X-API-Key header or a key query parameter.
+// With no key configured the API stays open.
+func requireAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.APIKey == "" || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := r.Header.Get("X-API-Key")
+		if key == "" {
+			key = r.URL.Query().Get("key")
+		}
+
+		if subtle.ConstantTimeCompare([]byte(key), []byte(cfg.APIKey)) != 1 {
+			writeJSONError(w, r, http.StatusUnauthorized, errUnauthorized, "Invalid or missing API key")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routeMethods lists the routes that accept more than GET and HEAD, by
+// mux pattern.
+var routeMethods = map[string]string{
+	"/admin/maintenance": "GET, HEAD, POST, OPTIONS",
+	"/admin/saved":       "GET, HEAD, POST, DELETE, OPTIONS",
+}
+
+// allowedMethods returns the Allow header value for the route r is
+// routed to, or false when no route matches.
+func allowedMethods(r *http.Request) (string, bool) {
+	_, pattern := http.DefaultServeMux.Handler(r)
+	if pattern == "" || (pattern == "/" && r.URL.Path != "/") {
+		return "", false
+	}
+	// the admin routes stay hidden until a token is configured
+	if strings.HasPrefix(pattern, "/admin/") && cfg.AdminToken == "" {
+		return "", false
+	}
+	if methods, ok := routeMethods[pattern]; ok {
+		return methods, true
+	}
+	return "GET, HEAD, OPTIONS", true
+}
+
+// answerOptions replies to OPTIONS requests with a 204 and the route's
+// Allow header. Unknown paths fall through to the usual 404.
+func answerOptions(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		methods, ok := allowedMethods(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Allow", methods)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// cors adds CORS headers to the /api/ routes so browser apps on other
+// origins can call them, and answers preflight requests directly. It
+// sits in front of requireAPIKey since preflights carry no credentials.
+// HTML routes are left alone.
+func cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", cfg.CORSOrigin)
+		w.Header().Set("Access-Control-Expose-Headers", "X-Total-Count")
+		if cfg.CORSOrigin != "*" {
+			w.Header().Add("Vary", "Origin")
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			methods, ok := allowedMethods(r)
+			if !ok {
+				methods = "GET, HEAD, OPTIONS"
+			}
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-API-Key")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitConcurrency caps the number of requests handled at once at
+// cfg.MaxConcurrentRequests. Requests over the cap are turned away with
+// a 503 straight away rather than queued, so a slow upstream can't pile
+// up goroutines.
+func limitConcurrency(next http.Handler) http.Handler {
+	sem := make(chan struct{}, cfg.MaxConcurrentRequests)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case sem <- struct{}{}:
+		default:
+			w.Header().Set("Retry-After", "1")
+			if strings.HasPrefix(r.URL.Path, "/api/") {
+				writeJSONError(w, r, http.StatusServiceUnavailable, errServerBusy, "Server busy")
+			} else {
+				renderError(w, http.StatusServiceUnavailable, "Server busy, please try again shortly")
+			}
+			return
+		}
+		defer func() { <-sem }()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withTimeout bounds each request's context by cfg.RequestTimeout. The
+// upstream fetches run under that context, so a slow upstream ends in a
+// 504 instead of holding the request open.
+func withTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), cfg.RequestTimeout)
+		defer cancel()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// recoverPanics turns a panicking handler into a logged stack trace and
+// a 500 page instead of a dropped connection. If the handler had already
+// started the response, the error page is appended to whatever was sent.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+
+			log.Printf("Panic serving %s %s: %v\n%s", r.Method, r.URL.RequestURI(), err, debug.Stack())
+			if strings.HasPrefix(r.URL.Path, "/api/") {
+				writeJSONError(w, r, http.StatusInternalServerError, errInternal, "Internal Server Error")
+			} else {
+				renderError(w, http.StatusInternalServerError, "Something went wrong")
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimiter counts requests per client IP in fixed one-minute windows.
+var rateLimiter = struct {
+	sync.Mutex
+	window time.Time
+	counts map[string]int
+}{counts: make(map[string]int)}
+
+// rateLimit allows each client cfg.RateLimit requests per minute and
+// answers 429 beyond that. It is off when RATE_LIMIT is unset.
+func rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.RateLimit == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		now := time.Now()
+		ip := clientIP(r)
+
+		rateLimiter.Lock()
+		if window := now.Truncate(time.Minute); !window.Equal(rateLimiter.window) {
+			rateLimiter.window = window
+			clear(rateLimiter.counts)
+		}
+		rateLimiter.counts[ip]++
+		over := rateLimiter.counts[ip] > cfg.RateLimit
+		retryAfter := rateLimiter.window.Add(time.Minute).Sub(now)
+		rateLimiter.Unlock()
+
+		if over {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			if strings.HasPrefix(r.URL.Path, "/api/") {
+				writeJSONError(w, r, http.StatusTooManyRequests, errRateLimited, "Too many requests")
+			} else {
+				renderError(w, http.StatusTooManyRequests, "Too many requests, please slow down")
+			}
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g.
+// to flush a streamed response.
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// logRequests logs one line per request with the client IP, status and
+// duration when cfg.LogRequests is set.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !cfg.LogRequests {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		log.Printf("%s %s %s %d %s", clientIP(r), r.Method, r.URL.RequestURI(), rec.status, time.Since(start).Round(time.Microsecond))
+	})
+}
+
+// maintenanceMode answers every user-facing route with a 503 while
+// maintenance mode is on. Health checks, the admin routes and the static
+// assets the maintenance page needs keep working.
+func maintenanceMode(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := r.URL.Path
+		if !maintenance.Load() || p == "/healthz" || strings.HasPrefix(p, "/admin/") || strings.HasPrefix(p, "/static/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Retry-After", "300")
+		if strings.HasPrefix(p, "/api/") {
+			writeJSONError(w, r, http.StatusServiceUnavailable, errMaintenance, "Down for maintenance")
+		} else {
+			renderError(w, http.StatusServiceUnavailable, "We're down for maintenance and will be back shortly.")
+		}
+	})
+}
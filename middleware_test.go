@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCanonicalPathStaysOnSite(t *testing.T) {
+	passed := false
+	handler := canonicalPath(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		passed = true
+	}))
+
+	tests := []struct {
+		path     string
+		location string // "" when the request is passed on unchanged
+	}{
+		{"/artist/", "/artist"},
+		{"//evil.com/", "/evil.com"},
+		{"/\\evil.com/", ""},
+	}
+	for _, tt := range tests {
+		passed = false
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.URL.Path = tt.path
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		loc := rec.Header().Get("Location")
+		if strings.HasPrefix(loc, "//") || strings.HasPrefix(loc, "/\\") {
+			t.Errorf("%q redirects off-site to %q", tt.path, loc)
+		}
+		if tt.location == "" {
+			if !passed || loc != "" {
+				t.Errorf("%q: redirected to %q, want it passed on", tt.path, loc)
+			}
+			continue
+		}
+		if rec.Code != http.StatusMovedPermanently || loc != tt.location {
+			t.Errorf("%q: %d to %q, want 301 to %q", tt.path, rec.Code, loc, tt.location)
+		}
+	}
+}
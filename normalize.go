@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// normalizeSearch folds s for forgiving comparisons: it is decomposed
+// (NFD) so accents become separate marks, then marks and punctuation are
+// dropped and the rest lowercased. "Motörhead" and "AC/DC" come out as
+// "motorhead" and "acdc".
+func normalizeSearch(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if unicode.Is(unicode.Mn, r) || unicode.IsPunct(r) {
+			continue
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// foldRune is normalizeSearch for a single rune: the lowercased base
+// letter without its accents. It keeps a one-to-one rune mapping so
+// highlight offsets stay valid.
+func foldRune(r rune) rune {
+	for _, d := range norm.NFD.String(string(r)) {
+		if !unicode.Is(unicode.Mn, d) {
+			return unicode.ToLower(d)
+		}
+	}
+	return unicode.ToLower(r)
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// pageCache holds rendered index pages keyed by indexCacheKey, so
+// repeated searches skip filtering and rendering. It is bounded by
+// cfg.PageCacheSize distinct keys.
+var pageCache *lruCache[string, []byte]
+
+// indexCacheKey identifies a rendered index page. Everything the page
+// depends on goes in: the dataset version and whether it is stale, the
+// query string, the view and page size (which may come from cookies), the
+// date layout, and the day, which decides past vs upcoming concerts.
+func indexCacheKey(data *Dataset, rawQuery string, p IndexParams, dateLayout string, now time.Time) string {
+	return fmt.Sprintf("%d|%t|%s|%s|%d|%s|%s",
+		data.FetchedAt.UnixNano(), data.Stale(), rawQuery, p.View, p.PageSize, dateLayout, now.Format(time.DateOnly))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// usePageCache swaps in an empty page cache of the given size for the
+// duration of the test.
+func usePageCache(t *testing.T, size int) {
+	t.Helper()
+	prev := pageCache
+	pageCache = newLRUCache[string, []byte](size)
+	t.Cleanup(func() { pageCache = prev })
+}
+
+func getIndex(t *testing.T, query string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	withRequestData(http.HandlerFunc(handleIndex)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?"+query, nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /?%s: status %d", query, rec.Code)
+	}
+}
+
+func TestPageCacheHitsAndMisses(t *testing.T) {
+	useTemplates(t)
+	usePageCache(t, 10)
+
+	getIndex(t, "q=queen")
+	getIndex(t, "q=queen")
+	getIndex(t, "q=pink")
+
+	if s := pageCache.Stats(); s.Hits != 1 || s.Misses != 2 || s.Size != 2 {
+		t.Errorf("Stats() = %+v, want 1 hit, 2 misses, 2 pages", s)
+	}
+}
+
+func TestPageCacheStaysWithinBound(t *testing.T) {
+	useTemplates(t)
+	const size, queries = 5, 40
+	usePageCache(t, size)
+
+	var wg sync.WaitGroup
+	for i := range queries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			getIndex(t, fmt.Sprintf("q=queen&page=%d", i))
+		}()
+	}
+	wg.Wait()
+
+	s := pageCache.Stats()
+	if s.Size != size || s.Max != size {
+		t.Errorf("Stats() = %+v after %d distinct queries, want %d pages", s, queries, size)
+	}
+	if s.Evictions != queries-size {
+		t.Errorf("%d evictions, want %d", s.Evictions, queries-size)
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Query parameter policy: every parameter is single-valued except
+// members, which may repeat to select several buckets. Repeating a
+// single-valued parameter with the same value is harmless and accepted;
+// repeating it with different values (?q=a&q=b) is ambiguous and is
+// rejected with a 400 rather than silently picking one. Parameters with
+// a fixed set of values ignore unknown ones unless cfg.StrictParams is
+// set; see enumParam.
+
+const maxQueryLength = 30
+
+// singleParam returns the value of a single-valued parameter, or "" if it
+// is absent.
+func singleParam(values url.Values, name string) (string, error) {
+	vs := values[name]
+	if len(vs) == 0 {
+		return "", nil
+	}
+	for _, v := range vs[1:] {
+		if v != vs[0] {
+			return "", fmt.Errorf("Conflicting values for parameter %q", name)
+		}
+	}
+	return vs[0], nil
+}
+
+// enumParam reads a single-valued parameter that takes one of allowed,
+// returning def when it is absent. An unknown value is rejected under
+// cfg.StrictParams, naming the accepted ones, and replaced by def
+// otherwise.
+func enumParam(values url.Values, name, def string, allowed ...string) (string, error) {
+	v, err := singleParam(values, name)
+	if err != nil {
+		return "", err
+	}
+	if v == "" {
+		return def, nil
+	}
+	if slices.Contains(allowed, v) {
+		return v, nil
+	}
+	if cfg.StrictParams {
+		return "", fmt.Errorf("Invalid %s %q, accepted values: %s", name, v, strings.Join(allowed, ", "))
+	}
+	return def, nil
+}
+
+// indexParamNames are the query parameters parseIndexParams reads.
+var indexParamNames = []string{
+	"q", "terms", "startsWith", "members", "location", "year",
+	"timeframe", "complete", "match", "sort", "view", "page", "pageSize",
+}
+
+// indexQuery keeps only the index parameters of values, for carrying the
+// current search and filters through to other pages and back.
+func indexQuery(values url.Values) url.Values {
+	kept := make(url.Values)
+	for _, name := range indexParamNames {
+		if vs, ok := values[name]; ok {
+			kept[name] = vs
+		}
+	}
+	return kept
+}
+
+// IndexParams are the search, filter and pagination settings of the
+// artist list.
+type IndexParams struct {
+	Query     string
+	Members   []MemberRange
+	Timeframe string
+	Page      int
+	PageSize  int
+	View      string
+	Complete  bool
+	Location  string
+	Year      int
+	Match     string
+
+	// Sort is "" for the upstream order, or "concerts" for the most
+	// concerts (date-location pairs) first
+	Sort string
+
+	// StartsWith is an upper-case initial letter, or "#" for names that
+	// don't start with a letter
+	StartsWith string
+
+	// Terms are the whitespace-separated words of Query; TermMode says
+	// whether an artist must match "all" of them or "any" one
+	Terms    []string
+	TermMode string
+
+	// QueryTooShort is set for queries under cfg.MinQueryLength; they
+	// don't filter anything
+	QueryTooShort bool
+}
+
+// needsFullData reports whether p uses the locations, dates or relation
+// data, which plain artist listings skip fetching. The text search does,
+// since it matches locations too.
+func (p IndexParams) needsFullData() bool {
+	return (len(p.Terms) > 0 && !p.QueryTooShort) || p.Location != "" ||
+		p.Timeframe != "" || p.Complete || p.Sort == "concerts"
+}
+
+// parseIndexParams reads and validates the index parameters. The error
+// message is suitable for showing to the client.
+func parseIndexParams(values url.Values) (IndexParams, error) {
+	var p IndexParams
+
+	q, err := singleParam(values, "q")
+	if err != nil {
+		return p, err
+	}
+	p.Query = strings.ToLower(strings.TrimSpace(q))
+	if len(p.Query) >= maxQueryLength {
+		return p, errors.New("Limit reached")
+	}
+	p.QueryTooShort = p.Query != "" && len([]rune(p.Query)) < cfg.MinQueryLength
+	p.Terms = strings.Fields(p.Query)
+
+	if p.TermMode, err = enumParam(values, "terms", "all", "all", "any"); err != nil {
+		return p, err
+	}
+
+	for _, m := range values["members"] {
+		if m == "" {
+			continue
+		}
+		mr, err := parseMemberRange(m)
+		if err != nil {
+			return p, err
+		}
+		p.Members = append(p.Members, mr)
+	}
+
+	if p.Timeframe, err = enumParam(values, "timeframe", "", "past", "upcoming"); err != nil {
+		return p, err
+	}
+
+	size, err := singleParam(values, "pageSize")
+	if err != nil {
+		return p, err
+	}
+	if p.PageSize, err = parsePageSize(size); err != nil {
+		return p, errors.New("Invalid page size")
+	}
+
+	page, err := singleParam(values, "page")
+	if err != nil {
+		return p, err
+	}
+	p.Page = 1
+	if page != "" {
+		if p.Page, err = strconv.Atoi(page); err != nil {
+			return p, errors.New("Invalid page")
+		}
+	}
+
+	if p.View, err = enumParam(values, "view", "", "cards", "table"); err != nil {
+		return p, err
+	}
+
+	complete, err := enumParam(values, "complete", "0", "0", "1")
+	if err != nil {
+		return p, err
+	}
+	p.Complete = complete == "1"
+
+	location, err := singleParam(values, "location")
+	if err != nil {
+		return p, err
+	}
+	p.Location = strings.ToLower(location)
+
+	year, err := singleParam(values, "year")
+	if err != nil {
+		return p, err
+	}
+	if year != "" {
+		if p.Year, err = strconv.Atoi(year); err != nil || p.Year < 1 {
+			return p, errors.New("Invalid year")
+		}
+	}
+
+	if p.Match, err = enumParam(values, "match", "all", "all", "any"); err != nil {
+		return p, err
+	}
+
+	if p.Sort, err = enumParam(values, "sort", "", "concerts"); err != nil {
+		return p, err
+	}
+
+	startsWith, err := singleParam(values, "startsWith")
+	if err != nil {
+		return p, err
+	}
+	if startsWith != "" {
+		r, size := utf8.DecodeRuneInString(startsWith)
+		if size != len(startsWith) || (r != '#' && !unicode.IsLetter(r)) {
+			return p, errors.New("Invalid startsWith letter")
+		}
+		p.StartsWith = strings.ToUpper(startsWith)
+	}
+
+	return p, nil
+}
+
+// checkKnownValues rejects, under cfg.StrictParams, a location or
+// startsWith letter that no artist in data has. parseIndexParams can only
+// check their syntax, since it runs before the data is loaded. Without
+// the location data the location is let through.
+func (p IndexParams) checkKnownValues(data *Dataset) error {
+	if !cfg.StrictParams {
+		return nil
+	}
+	if p.Location != "" && data.ArtistsByLocation != nil && data.ArtistsByLocation[p.Location] == nil {
+		return fmt.Errorf("Unknown location %q, see /api/filters for the accepted values", p.Location)
+	}
+	if p.StartsWith != "" {
+		letters := buildFacetOptions(data).Letters
+		if !slices.Contains(letters, p.StartsWith) {
+			return fmt.Errorf("Invalid startsWith %q, accepted values: %s", p.StartsWith, strings.Join(letters, ", "))
+		}
+	}
+	return nil
+}
+
+// MemberRange is an inclusive range of member counts. Max is -1 when
+// the range has no upper bound.
+type MemberRange struct {
+	Min, Max int
+}
+
+// Contains reports whether n members fall within the range.
+func (m MemberRange) Contains(n int) bool {
+	return n >= m.Min && (m.Max < 0 || n <= m.Max)
+}
+
+// String formats the range in the syntax parseMemberRange accepts.
+func (m MemberRange) String() string {
+	switch {
+	case m.Max < 0:
+		return strconv.Itoa(m.Min) + "-"
+	case m.Min == m.Max:
+		return strconv.Itoa(m.Min)
+	default:
+		return strconv.Itoa(m.Min) + "-" + strconv.Itoa(m.Max)
+	}
+}
+
+// parseMemberRange parses a members value: "3" is exactly three
+// members, "2-4" two to four inclusive and "5-" five or more.
+func parseMemberRange(s string) (MemberRange, error) {
+	invalid := errors.New("Invalid members value")
+
+	lo, hi, isRange := strings.Cut(s, "-")
+	minN, err := strconv.Atoi(lo)
+	if err != nil || minN < 0 {
+		return MemberRange{}, invalid
+	}
+	if !isRange {
+		return MemberRange{Min: minN, Max: minN}, nil
+	}
+	if hi == "" {
+		return MemberRange{Min: minN, Max: -1}, nil
+	}
+	maxN, err := strconv.Atoi(hi)
+	if err != nil || maxN < minN {
+		return MemberRange{}, invalid
+	}
+	return MemberRange{Min: minN, Max: maxN}, nil
+}
+
+// parseID reads a required, single-valued integer id parameter.
+func parseID(values url.Values) (int, error) {
+	s, err := singleParam(values, "id")
+	if err != nil {
+		return 0, err
+	}
+	if s == "" {
+		return 0, errors.New("Missing artist id")
+	}
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.New("Invalid artist id")
+	}
+	return id, nil
+}
+
+// parseIDList parses a comma-separated list of positive artist IDs,
+// dropping duplicates but keeping the order given.
+func parseIDList(s string) ([]int, error) {
+	var ids []int
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(s, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || id <= 0 {
+			return nil, errors.New("Invalid artist id")
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestParseMemberRange(t *testing.T) {
+	valid := []struct {
+		in   string
+		want MemberRange
+	}{
+		{"3", MemberRange{Min: 3, Max: 3}},
+		{"2-4", MemberRange{Min: 2, Max: 4}},
+		{"5-", MemberRange{Min: 5, Max: -1}},
+	}
+	for _, tt := range valid {
+		got, err := parseMemberRange(tt.in)
+		if err != nil {
+			t.Errorf("parseMemberRange(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseMemberRange(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		if s := got.String(); s != tt.in {
+			t.Errorf("parseMemberRange(%q).String() = %q", tt.in, s)
+		}
+	}
+
+	for _, in := range []string{"4-2", "-1", "x"} {
+		if got, err := parseMemberRange(in); err == nil {
+			t.Errorf("parseMemberRange(%q) = %+v, want an error", in, got)
+		}
+	}
+}
+
+func TestCheckKnownValues(t *testing.T) {
+	data := loadFixtures(t)
+	prev := cfg.StrictParams
+	t.Cleanup(func() { cfg.StrictParams = prev })
+
+	tests := []struct {
+		params IndexParams
+		ok     bool
+	}{
+		{IndexParams{}, true},
+		{IndexParams{Location: "osaka-japan"}, true},
+		{IndexParams{Location: "atlantis"}, false},
+		{IndexParams{StartsWith: "Q"}, true},
+		{IndexParams{StartsWith: "Z"}, false},
+	}
+	for _, tt := range tests {
+		cfg.StrictParams = true
+		if err := tt.params.checkKnownValues(data); (err == nil) != tt.ok {
+			t.Errorf("strict %+v: error %v, want ok=%v", tt.params, err, tt.ok)
+		}
+		cfg.StrictParams = false
+		if err := tt.params.checkKnownValues(data); err != nil {
+			t.Errorf("lenient %+v: %v", tt.params, err)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+// The upstream maps each location to the list of dates played there.
+func TestRelationDecodesDateLists(t *testing.T) {
+	body := `{"index": [{"id": 1, "datesLocations": {
+		"london-uk": ["*23-08-2019", "22-08-2019"],
+		"osaka-japan": ["28-01-2020"]
+	}}]}`
+
+	var rel RelationAPI
+	if err := json.Unmarshal([]byte(body), &rel); err != nil {
+		t.Fatal(err)
+	}
+	if len(rel.Index) != 1 {
+		t.Fatalf("decoded %d entries, want 1", len(rel.Index))
+	}
+	id, dl, err := decodeRelationEntry(rel.Index[0])
+	if err != nil || id != 1 {
+		t.Fatalf("decodeRelationEntry: id %d, err %v", id, err)
+	}
+	if got := dl["london-uk"]; !slices.Equal(got, []string{"*23-08-2019", "22-08-2019"}) {
+		t.Errorf("london-uk dates = %q", got)
+	}
+	if got := dl["osaka-japan"]; !slices.Equal(got, []string{"28-01-2020"}) {
+		t.Errorf("osaka-japan dates = %q", got)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// savedQueries maps the tokens of saved searches to their encoded index
+// parameters. Admins manage them at /admin/saved; anyone with a token
+// can run its search at /api/saved/{token}. With cfg.SavedQueriesFile
+// set they are persisted there as a JSON object.
+var savedQueries struct {
+	sync.RWMutex
+	byToken map[string]string
+}
+
+// savedTokenPattern is what a chosen token may look like; generated ones
+// are lowercase hex.
+var savedTokenPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// SavedQuery is a saved search as listed and returned by /admin/saved.
+type SavedQuery struct {
+	Token string `json:"token"`
+	Query string `json:"query"`
+	URL   string `json:"url"`
+}
+
+func newSavedQuery(token, query string) SavedQuery {
+	return SavedQuery{Token: token, Query: query, URL: urlFor("/api/saved/" + token)}
+}
+
+// loadSavedQueries reads the saved queries from path. A missing file is
+// an empty set; a file that doesn't parse is an error, so a later save
+// can't overwrite it.
+func loadSavedQueries(path string) error {
+	byToken := make(map[string]string)
+	if path != "" {
+		b, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return err
+		default:
+			if err := json.Unmarshal(b, &byToken); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	}
+
+	savedQueries.Lock()
+	savedQueries.byToken = byToken
+	savedQueries.Unlock()
+	return nil
+}
+
+// updateSavedQueries applies change to a copy of the saved queries,
+// persists it and only then makes it current, so a failed write leaves
+// both the file and memory as they were.
+func updateSavedQueries(change func(map[string]string)) error {
+	savedQueries.Lock()
+	defer savedQueries.Unlock()
+
+	next := maps.Clone(savedQueries.byToken)
+	if next == nil {
+		next = make(map[string]string)
+	}
+	change(next)
+
+	if cfg.SavedQueriesFile != "" {
+		if err := writeSavedQueries(cfg.SavedQueriesFile, next); err != nil {
+			return err
+		}
+	}
+	savedQueries.byToken = next
+	return nil
+}
+
+// writeSavedQueries replaces the file at path through a temporary file,
+// so readers never see it half written.
+func writeSavedQueries(path string, byToken map[string]string) error {
+	b, err := json.MarshalIndent(byToken, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".saved-*.json")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func lookupSavedQuery(token string) (string, bool) {
+	savedQueries.RLock()
+	defer savedQueries.RUnlock()
+	query, ok := savedQueries.byToken[token]
+	return query, ok
+}
+
+// newSavedToken returns a random token not yet in use.
+func newSavedToken() string {
+	for {
+		b := make([]byte, 5)
+		rand.Read(b)
+		token := hex.EncodeToString(b)
+		if _, taken := lookupSavedQuery(token); !taken {
+			return token
+		}
+	}
+}
+
+// handleSavedQueries serves /admin/saved: GET lists the saved queries,
+// POST saves query (an index query string such as "q=queen&members=4")
+// under name, or a generated token when name is empty, replacing any
+// query already saved there, and DELETE removes name.
+func handleSavedQueries(w http.ResponseWriter, r *http.Request) {
+
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		savedQueries.RLock()
+		list := make([]SavedQuery, 0, len(savedQueries.byToken))
+		for token, query := range savedQueries.byToken {
+			list = append(list, newSavedQuery(token, query))
+		}
+		savedQueries.RUnlock()
+
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Token < list[j].Token
+		})
+		writeJSON(w, r, http.StatusOK, list)
+
+	case http.MethodPost:
+		values, err := url.ParseQuery(strings.TrimPrefix(r.FormValue("query"), "?"))
+		if err != nil {
+			writeJSONError(w, r, http.StatusBadRequest, errInvalidParameter, "Invalid query string")
+			return
+		}
+		values = indexQuery(values)
+		if _, err := parseIndexParams(values); err != nil {
+			writeJSONError(w, r, http.StatusBadRequest, errInvalidParameter, err.Error())
+			return
+		}
+
+		token := r.FormValue("name")
+		if token == "" {
+			token = newSavedToken()
+		} else if !savedTokenPattern.MatchString(token) {
+			writeJSONError(w, r, http.StatusBadRequest, errInvalidParameter, "name must be 1-32 lowercase letters, digits or dashes")
+			return
+		}
+
+		query := values.Encode()
+		if err := updateSavedQueries(func(m map[string]string) { m[token] = query }); err != nil {
+			log.Printf("Saving query %q: %v", token, err)
+			writeJSONError(w, r, http.StatusInternalServerError, errInternal, "Failed to save query")
+			return
+		}
+		writeJSON(w, r, http.StatusOK, newSavedQuery(token, query))
+
+	case http.MethodDelete:
+		token := r.FormValue("name")
+		if _, ok := lookupSavedQuery(token); !ok {
+			writeJSONError(w, r, http.StatusNotFound, errNotFound, "Saved query not found")
+			return
+		}
+		if err := updateSavedQueries(func(m map[string]string) { delete(m, token) }); err != nil {
+			log.Printf("Deleting query %q: %v", token, err)
+			writeJSONError(w, r, http.StatusInternalServerError, errInternal, "Failed to delete query")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST, DELETE, OPTIONS")
+		writeJSONError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// handleSavedQuery serves /api/saved/{token}: the /api/search result for
+// the saved query, against the current data.
+func handleSavedQuery(w http.ResponseWriter, r *http.Request) {
+
+	if !allowGetHead(w, r) {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	query, ok := lookupSavedQuery(strings.TrimPrefix(r.URL.Path, "/api/saved/"))
+	if !ok {
+		writeJSONError(w, r, http.StatusNotFound, errNotFound, "Saved query not found")
+		return
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		writeJSONError(w, r, http.StatusInternalServerError, errInternal, "Saved query is corrupt")
+		return
+	}
+
+	serveSearch(w, r, values)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// slugify turns an artist name into a URL-safe slug: accents are
+// stripped, letters and digits lowercased, and every other run of
+// characters becomes a single hyphen. "Motörhead" becomes "motorhead",
+// "AC/DC" becomes "ac-dc".
+func slugify(name string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range norm.NFD.String(name) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+		case r == '\'' || r == '’':
+			// apostrophes join words: "Guns N' Roses" → "guns-n-roses"
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			hyphen = false
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			hyphen = true
+		}
+	}
+	return b.String()
+}
+
+// assignSlugs sets each artist's Slug and builds data.ArtistsBySlug.
+// Artists are taken in ID order so collisions resolve the same way on
+// every refresh: the lowest ID keeps the plain slug, later ones get
+// their ID appended, again if need be, until the slug is free. Names
+// with nothing sluggable fall back to "artist-{id}", and all-digit slugs
+// such as "311" become "artist-311" so they aren't taken for an ID.
+func assignSlugs(data *Dataset) {
+	order := make([]int, len(data.Artists))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return data.Artists[order[i]].ID < data.Artists[order[j]].ID
+	})
+
+	data.ArtistsBySlug = make(map[string]int, len(data.Artists))
+	for _, i := range order {
+		a := &data.Artists[i]
+		id := strconv.Itoa(a.ID)
+		slug := slugify(a.Name)
+		switch {
+		case slug == "":
+			slug = "artist-" + id
+		case isDigits(slug):
+			slug = "artist-" + slug
+		}
+		for {
+			if _, taken := data.ArtistsBySlug[slug]; !taken {
+				break
+			}
+			slug += "-" + id
+		}
+		a.Slug = slug
+		data.ArtistsBySlug[slug] = a.ID
+	}
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveArtist finds an artist by numeric ID or by slug.
+func (d *Dataset) resolveArtist(ref string) (Artist, bool) {
+	if id, err := strconv.Atoi(ref); err == nil {
+		return d.Artist(id)
+	}
+	id, ok := d.ArtistsBySlug[strings.ToLower(ref)]
+	if !ok {
+		return Artist{}, false
+	}
+	return d.Artist(id)
+}
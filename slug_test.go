@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestAssignSlugs(t *testing.T) {
+	data := &Dataset{Artists: []Artist{
+		{ID: 7, Name: "Queen"},
+		{ID: 1, Name: "Queen"},
+		{ID: 5, Name: "Queen 7"},
+		{ID: 3, Name: "311"},
+		{ID: 9, Name: "???"},
+	}}
+	assignSlugs(data)
+
+	want := map[int]string{
+		1: "queen",
+		3: "artist-311",
+		5: "queen-7",
+		7: "queen-7-7",
+		9: "artist-9",
+	}
+	for _, a := range data.Artists {
+		if a.Slug != want[a.ID] {
+			t.Errorf("artist %d: slug %q, want %q", a.ID, a.Slug, want[a.ID])
+		}
+		if got := data.ArtistsBySlug[a.Slug]; got != a.ID {
+			t.Errorf("ArtistsBySlug[%q] = %d, want %d", a.Slug, got, a.ID)
+		}
+	}
+	if len(data.ArtistsBySlug) != len(data.Artists) {
+		t.Errorf("%d slugs for %d artists", len(data.ArtistsBySlug), len(data.Artists))
+	}
+}
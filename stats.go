@@ -0,0 +1,152 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const defaultTopArtists = 10
+
+// artistViews counts detail page views per artist ID since startup.
+var artistViews = struct {
+	sync.Mutex
+	counts map[int]int
+}{counts: make(map[int]int)}
+
+type ArtistViewCount struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Views int    `json:"views"`
+}
+
+// fetchTimings tracks how long each upstream endpoint took to answer,
+// keyed by endpoint name.
+var fetchTimings = struct {
+	sync.Mutex
+	byEndpoint map[string]*fetchTiming
+}{byEndpoint: make(map[string]*fetchTiming)}
+
+type fetchTiming struct {
+	count int
+	last  time.Duration
+	total time.Duration
+}
+
+// FetchDuration reports upstream timings in milliseconds.
+type FetchDuration struct {
+	Count  int     `json:"count"`
+	LastMs float64 `json:"lastMs"`
+	AvgMs  float64 `json:"avgMs"`
+}
+
+type StatsData struct {
+	TopArtists     []ArtistViewCount        `json:"topArtists"`
+	FetchDurations map[string]FetchDuration `json:"fetchDurations"`
+	Caches         map[string]CacheStats    `json:"caches"`
+}
+
+func recordArtistView(id int) {
+	artistViews.Lock()
+	artistViews.counts[id]++
+	artistViews.Unlock()
+}
+
+func recordFetchDuration(endpoint string, d time.Duration) {
+	fetchTimings.Lock()
+	t := fetchTimings.byEndpoint[endpoint]
+	if t == nil {
+		t = &fetchTiming{}
+		fetchTimings.byEndpoint[endpoint] = t
+	}
+	t.count++
+	t.last = d
+	t.total += d
+	fetchTimings.Unlock()
+}
+
+func fetchDurations() map[string]FetchDuration {
+	fetchTimings.Lock()
+	defer fetchTimings.Unlock()
+
+	result := make(map[string]FetchDuration, len(fetchTimings.byEndpoint))
+	for endpoint, t := range fetchTimings.byEndpoint {
+		result[endpoint] = FetchDuration{
+			Count:  t.count,
+			LastMs: milliseconds(t.last),
+			AvgMs:  milliseconds(t.total / time.Duration(t.count)),
+		}
+	}
+	return result
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// topArtistViews returns the n most viewed artist IDs, most viewed first.
+func topArtistViews(n int) []ArtistViewCount {
+	artistViews.Lock()
+	result := make([]ArtistViewCount, 0, len(artistViews.counts))
+	for id, views := range artistViews.counts {
+		result = append(result, ArtistViewCount{ID: id, Views: views})
+	}
+	artistViews.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Views != result[j].Views {
+			return result[i].Views > result[j].Views
+		}
+		return result[i].ID < result[j].ID
+	})
+	if len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+func handleStats(w http.ResponseWriter, r *http.Request) {
+
+	if !allowGetHead(w, r) {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	s, err := singleParam(r.URL.Query(), "top")
+	if err != nil {
+		writeJSONError(w, r, http.StatusBadRequest, errInvalidParameter, err.Error())
+		return
+	}
+
+	n := defaultTopArtists
+	if s != "" {
+		n, err = strconv.Atoi(s)
+		if err != nil || n < 1 {
+			writeJSONError(w, r, http.StatusBadRequest, errInvalidParameter, "Invalid top value")
+			return
+		}
+	}
+
+	top := topArtistViews(n)
+
+	// names are best effort; the counts are still useful without them
+	if data, err := loadAllData(r.Context()); err == nil {
+		for i := range top {
+			if a, found := data.Artist(top[i].ID); found {
+				top[i].Name = a.Name
+			}
+		}
+	}
+
+	writeJSON(w, r, http.StatusOK, StatsData{
+		TopArtists:     top,
+		FetchDurations: fetchDurations(),
+		Caches: map[string]CacheStats{
+			"pages":  pageCache.Stats(),
+			"images": imageCache.Stats(),
+			"geo":    geoCache.Stats(),
+		},
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// maxSuggestionDistance caps how many edits a "did you mean" suggestion
+// may be away from the query.
+const maxSuggestionDistance = 3
+
+// suggestArtistName returns the artist name closest to query by edit
+// distance, or "" when nothing is close enough to be a plausible typo.
+func suggestArtistName(artists []Artist, query string) string {
+	query = strings.ToLower(query)
+
+	// short queries get a tighter limit so "ab" doesn't suggest "ABBA"
+	limit := min(maxSuggestionDistance, len([]rune(query))/2)
+
+	best, bestDist := "", limit+1
+	for _, a := range artists {
+		d := levenshtein(query, strings.ToLower(a.Name))
+		if d < bestDist {
+			best, bestDist = a.Name, d
+		}
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between a and b, in runes.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// searchURL returns the current index URL with the search term replaced,
+// back on the first page.
+func searchURL(r *http.Request, q string) string {
+	values := r.URL.Query()
+	values.Set("q", q)
+	values.Del("page")
+	return urlFor("/?" + values.Encode())
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+)
+
+// fixtureFS holds a small snapshot of the upstream API, served instead
+// of the network when OFFLINE is set.
+//
+//go:embed fixtures/*.json
+var fixtureFS embed.FS
+
+// openUpstream returns the body of the named API endpoint (e.g.
+// "artists") under cfg.APIBaseURL, or of the matching fixture file in
+// offline mode. The caller closes it.
+func openUpstream(ctx context.Context, endpoint string) (io.ReadCloser, error) {
+	if cfg.Offline {
+		return fixtureFS.Open(path.Join("fixtures", endpoint+".json"))
+	}
+
+	url := cfg.APIBaseURL + "/" + endpoint
+	req, err := newOutboundRequest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: status %d", url, resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// newOutboundRequest builds a GET request for url carrying cfg.UserAgent.
+// Every request to another service (the API, images, geocoding) is made
+// through here so the upstreams' logs can tell who is calling.
+func newOutboundRequest(ctx context.Context, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", cfg.UserAgent)
+	return req, nil
+}
+
+// decodeUpstream fetches an endpoint (or fixture) and decodes its JSON
+// body into v. Bodies larger than cfg.MaxUpstreamBytes are rejected so a
+// broken upstream can't exhaust memory.
+func decodeUpstream(ctx context.Context, endpoint string, v any) error {
+	// the timing covers the request and reading the body, which is where
+	// a slow upstream shows up
+	start := time.Now()
+	defer func() { recordFetchDuration(endpoint, time.Since(start)) }()
+
+	body, err := openUpstream(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	// read one byte past the cap so hitting it can be told apart from a
+	// body of exactly the maximum size
+	limited := &io.LimitedReader{R: body, N: cfg.MaxUpstreamBytes + 1}
+	err = json.NewDecoder(limited).Decode(v)
+	if limited.N <= 0 {
+		return fmt.Errorf("%s: response larger than %d bytes", endpoint, cfg.MaxUpstreamBytes)
+	}
+	return err
+}
+
+// upstreamEndpoints are the API resources the dataset is built from.
+var upstreamEndpoints = []string{"artists", "locations", "dates", "relation"}
+
+// probeTimeout bounds each endpoint check at startup.
+const probeTimeout = 5 * time.Second
+
+// probeUpstream checks concurrently that every upstream endpoint answers,
+// logging the result for each, and reports whether all of them did.
+func probeUpstream() bool {
+	var wg sync.WaitGroup
+	errs := make([]error, len(upstreamEndpoints))
+	for i, endpoint := range upstreamEndpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+			defer cancel()
+
+			body, err := openUpstream(ctx, endpoint)
+			if err == nil {
+				body.Close()
+			}
+			errs[i] = err
+		}()
+	}
+	wg.Wait()
+
+	ok := true
+	for i, endpoint := range upstreamEndpoints {
+		if errs[i] != nil {
+			log.Printf("Upstream %s unreachable: %v", endpoint, errs[i])
+			ok = false
+			continue
+		}
+		log.Printf("Upstream %s reachable", endpoint)
+	}
+	return ok
+}
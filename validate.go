@@ -0,0 +1,41 @@
+package main
+
+import "fmt"
+
+// The validate functions check decoded upstream data against the
+// invariants the handlers rely on, so format drift surfaces as an error
+// instead of blank or misattributed pages. Relation entries are checked
+// one by one while decoding instead; see decodeRelationEntry.
+
+func validateArtists(artists []Artist) error {
+	if len(artists) == 0 {
+		return fmt.Errorf("artists: empty response")
+	}
+	for i, a := range artists {
+		if a.ID <= 0 {
+			return fmt.Errorf("artists[%d]: invalid id %d", i, a.ID)
+		}
+		if a.Name == "" {
+			return fmt.Errorf("artists[%d] (id %d): empty name", i, a.ID)
+		}
+	}
+	return nil
+}
+
+func validateLocations(data LocationsAPI) error {
+	for i, entry := range data.Index {
+		if entry.ID <= 0 {
+			return fmt.Errorf("locations[%d]: invalid id %d", i, entry.ID)
+		}
+	}
+	return nil
+}
+
+func validateDates(data DatesAPI) error {
+	for i, entry := range data.Index {
+		if entry.ID <= 0 {
+			return fmt.Errorf("dates[%d]: invalid id %d", i, entry.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import "net/http"
+
+// Build information, set at build time with e.g.
+//
+//	go build -ldflags "-X main.version=1.2.0 -X main.commit=$(git rev-parse --short HEAD) -X main.buildTime=$(date -u +%FT%TZ)"
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildTime = "unknown"
+)
+
+type VersionInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"buildTime"`
+}
+
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+
+	if !allowGetHead(w, r) {
+		writeJSONError(w, r, http.StatusMethodNotAllowed, errMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, VersionInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildTime: buildTime,
+	})
+}